@@ -0,0 +1,257 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/colakuma/server-tool/table-gen/gtrt"
+)
+
+// dataRow 按表字段整理的一行数据
+type dataRow struct {
+	Key    string            `json:"key"`
+	Fields map[string]string `json:"fields"`
+}
+
+// fieldChange 单个字段的修改
+type fieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+type rowChange struct {
+	Key     string        `json:"key"`
+	Changes []fieldChange `json:"changes"`
+}
+
+// tableDiff 两个版本数据文件的差异，行按主键匹配，与行的顺序无关
+type tableDiff struct {
+	Table    string      `json:"table"`
+	Fields   []string    `json:"-"`
+	Added    []dataRow   `json:"added"`
+	Removed  []dataRow   `json:"removed"`
+	Modified []rowChange `json:"modified"`
+}
+
+// runDiff table-gen diff old.csv new.csv -table Item: 按主键比较两个版本的表数据
+func runDiff(args []string) error {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	table := fs.String("table", "", "table name, e.g. Item")
+	format := fs.String("format", "text", "output format: text, json or html")
+	// 允许参数写在文件名之后
+	files := make([]string, 0, 2)
+	for {
+		fs.Parse(args)
+		if fs.NArg() == 0 {
+			break
+		}
+		files = append(files, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	if len(files) != 2 || *table == "" {
+		return fmt.Errorf("usage: table-gen diff old.csv new.csv -table Item [-format text|json|html]")
+	}
+
+	cfiles, _ := enumFile(".", "c_")
+	for _, v := range cfiles {
+		walkFile(v)
+	}
+	ts, ok := tables[strings.ToLower(*table)]
+	if !ok {
+		return fmt.Errorf("table %s not found", *table)
+	}
+	makeTableStructStuff(ts, make(map[string]string))
+	if fatal {
+		return fmt.Errorf("failed to parse table %s", ts.typeName)
+	}
+
+	old, err := readDataRows(ts, files[0])
+	if err != nil {
+		return err
+	}
+	cur, err := readDataRows(ts, files[1])
+	if err != nil {
+		return err
+	}
+	d := diffDataRows(ts, old, cur)
+
+	switch *format {
+	case "text":
+		writeDiffText(os.Stdout, d)
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		return enc.Encode(d)
+	case "html":
+		return diffHTML.Execute(os.Stdout, d)
+	default:
+		return fmt.Errorf("unknown format %s", *format)
+	}
+	return nil
+}
+
+// readDataRows 读取数据文件，按表字段取值并以主键索引，主键重复时报错
+func readDataRows(ts *TableStruct, file string) (map[string]dataRow, error) {
+	rows, lines, err := readCSVLines(file)
+	if err != nil {
+		return nil, err
+	}
+	var header []string
+	m := make(map[string]dataRow)
+	for i, row := range rows {
+		if gtrt.IsCommentRow(row) {
+			continue
+		}
+		if header == nil {
+			header = row
+			names := make([]string, 0, len(ts.keyField))
+			for _, k := range ts.keyField {
+				names = append(names, k.column())
+			}
+			if lst := gtrt.MissingColumns(header, names...); len(lst) > 0 {
+				return nil, fmt.Errorf("%s: key column %s not found in header", file, lst[0])
+			}
+			continue
+		}
+		r := dataRow{Fields: make(map[string]string)}
+		for _, f := range columnFields(ts) {
+			idx := gtrt.BindColumns(header, f.column())[0]
+			if idx >= 0 && idx < len(row) {
+				r.Fields[f.name] = strings.TrimSpace(row[idx])
+			}
+		}
+		keys := make([]string, 0, len(ts.keyField))
+		for _, k := range ts.keyField {
+			keys = append(keys, r.Fields[k.name])
+		}
+		r.Key = strings.Join(keys, ",")
+		if _, ok := m[r.Key]; ok {
+			return nil, fmt.Errorf("%s:%d: duplicate key %s", file, lines[i], r.Key)
+		}
+		m[r.Key] = r
+	}
+	return m, nil
+}
+
+func diffDataRows(ts *TableStruct, old, cur map[string]dataRow) *tableDiff {
+	d := &tableDiff{Table: ts.typeName, Added: []dataRow{}, Removed: []dataRow{}, Modified: []rowChange{}}
+	for _, f := range columnFields(ts) {
+		d.Fields = append(d.Fields, f.name)
+	}
+	for _, k := range sortedDataKeys(cur) {
+		o, ok := old[k]
+		if !ok {
+			d.Added = append(d.Added, cur[k])
+			continue
+		}
+		var changes []fieldChange
+		for _, f := range d.Fields {
+			if o.Fields[f] != cur[k].Fields[f] {
+				changes = append(changes, fieldChange{Field: f, Old: o.Fields[f], New: cur[k].Fields[f]})
+			}
+		}
+		if len(changes) > 0 {
+			d.Modified = append(d.Modified, rowChange{Key: k, Changes: changes})
+		}
+	}
+	for _, k := range sortedDataKeys(old) {
+		if _, ok := cur[k]; !ok {
+			d.Removed = append(d.Removed, old[k])
+		}
+	}
+	return d
+}
+
+// sortedDataKeys 主键排序，数字按大小排
+func sortedDataKeys(m map[string]dataRow) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := strings.Split(keys[i], ","), strings.Split(keys[j], ",")
+		for n := 0; n < len(a) && n < len(b); n++ {
+			if a[n] == b[n] {
+				continue
+			}
+			x, err1 := strconv.ParseFloat(a[n], 64)
+			y, err2 := strconv.ParseFloat(b[n], 64)
+			if err1 == nil && err2 == nil {
+				return x < y
+			}
+			return a[n] < b[n]
+		}
+		return len(a) < len(b)
+	})
+	return keys
+}
+
+func (d *tableDiff) rowText(r dataRow) string {
+	lst := make([]string, 0, len(d.Fields))
+	for _, f := range d.Fields {
+		lst = append(lst, f+"="+r.Fields[f])
+	}
+	return strings.Join(lst, " ")
+}
+
+func writeDiffText(w io.Writer, d *tableDiff) {
+	fmt.Fprintf(w, "%s: %d added, %d removed, %d modified\n", d.Table, len(d.Added), len(d.Removed), len(d.Modified))
+	for _, v := range d.Added {
+		fmt.Fprintf(w, "+ %s: %s\n", v.Key, d.rowText(v))
+	}
+	for _, v := range d.Removed {
+		fmt.Fprintf(w, "- %s: %s\n", v.Key, d.rowText(v))
+	}
+	for _, v := range d.Modified {
+		fmt.Fprintf(w, "~ %s:\n", v.Key)
+		for _, c := range v.Changes {
+			fmt.Fprintf(w, "    %s: %q -> %q\n", c.Field, c.Old, c.New)
+		}
+	}
+}
+
+var diffHTML = template.Must(template.New("diff").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Table}} diff</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; margin-bottom: 1em; }
+td, th { border: 1px solid #ccc; padding: 2px 8px; }
+.add { background: #e6ffec; }
+.del { background: #ffebe9; }
+.old { color: #cf222e; text-decoration: line-through; }
+.new { color: #1a7f37; }
+</style>
+</head>
+<body>
+<h2>{{.Table}}: {{len .Added}} added, {{len .Removed}} removed, {{len .Modified}} modified</h2>
+{{$fields := .Fields}}
+{{if .Added}}<h3>Added</h3>
+<table>
+<tr>{{range $fields}}<th>{{.}}</th>{{end}}</tr>
+{{range .Added}}{{$r := .}}<tr class="add">{{range $fields}}<td>{{index $r.Fields .}}</td>{{end}}</tr>
+{{end}}</table>{{end}}
+{{if .Removed}}<h3>Removed</h3>
+<table>
+<tr>{{range $fields}}<th>{{.}}</th>{{end}}</tr>
+{{range .Removed}}{{$r := .}}<tr class="del">{{range $fields}}<td>{{index $r.Fields .}}</td>{{end}}</tr>
+{{end}}</table>{{end}}
+{{if .Modified}}<h3>Modified</h3>
+<table>
+<tr><th>key</th><th>field</th><th>before</th><th>after</th></tr>
+{{range .Modified}}{{$k := .Key}}{{range .Changes}}<tr><td>{{$k}}</td><td>{{.Field}}</td><td class="old">{{.Old}}</td><td class="new">{{.New}}</td></tr>
+{{end}}{{end}}</table>{{end}}
+</body>
+</html>
+`))
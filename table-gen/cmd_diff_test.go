@@ -0,0 +1,63 @@
+package main
+
+import (
+	"go/types"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// diffTable Id和Zone为主键，Name读取名为Title的列
+func diffTable() *TableStruct {
+	id := &TableField{name: "Id", typ: "int", vtype: types.Typ[types.Int], attrs: map[string]string{"key": ""}}
+	zone := &TableField{name: "Zone", typ: "string", vtype: types.Typ[types.String], attrs: map[string]string{"key": ""}}
+	name := &TableField{name: "Name", typ: "string", vtype: types.Typ[types.String], attrs: map[string]string{"col": "Title"}}
+	ts := &TableStruct{typeName: "Item"}
+	ts.fields = []*TableField{id, zone, name}
+	ts.keyField = []*TableField{id, zone}
+	return ts
+}
+
+func TestReadDataRows(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		keys string
+		err  string
+	}{
+		{name: "rows", data: "Id,Zone,Title\n1,a,x\n1,b,y\n", keys: "1,a;1,b"},
+		{
+			name: "duplicate after comments and a multi-line cell",
+			data: "# note\nId,Zone,Title\n\n1,a,\"two\nlines\"\n# skip\n1,a,z\n",
+			err:  "item.csv:7: duplicate key 1,a",
+		},
+		{name: "missing key column", data: "Id,Title\n1,x\n", err: "item.csv: key column Zone not found in header"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "item.csv")
+			if err := os.WriteFile(file, []byte(tt.data), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			m, err := readDataRows(diffTable(), file)
+			if tt.err != "" {
+				if err == nil || !strings.HasSuffix(err.Error(), tt.err) {
+					t.Fatalf("err = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, k := range strings.Split(tt.keys, ";") {
+				if _, ok := m[k]; !ok {
+					t.Errorf("key %s not found in %v", k, m)
+				}
+			}
+			if len(m) != len(strings.Split(tt.keys, ";")) {
+				t.Errorf("got %d rows, want %s", len(m), tt.keys)
+			}
+		})
+	}
+}
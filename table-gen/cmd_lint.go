@@ -0,0 +1,191 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/types"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+const lintHarness = "tablegen_lint_test.go"
+
+// runLint table-gen lint: 在测试程序中用生成的加载代码加载全部表，
+// 执行引用、唯一性、@check函数和孤立行检查，输出json报告
+func runLint(args []string) error {
+	fs := flag.NewFlagSet("lint", flag.ExitOnError)
+	data := fs.String("data", "data", "data directory")
+	out := fs.String("o", "", "write the json report to this file instead of stdout")
+	layers := fs.String("layers", "", "comma separated override layers, e.g. cn,cn/test")
+	lang := fs.String("lang", "", "language table for i18n fields, e.g. lang.csv")
+	locales := fs.String("locales", "", "comma separated locales of the language table, the first is the fallback")
+	fs.Parse(args)
+
+	dataDir, err := filepath.Abs(*data)
+	if err != nil {
+		return err
+	}
+
+	files, _ := enumFile(".", "c_")
+	for _, v := range files {
+		walkFile(v)
+	}
+	lst := make([]*TableStruct, 0, len(tables))
+	for _, v := range tables {
+		lst = append(lst, v)
+	}
+	sort.Slice(lst, func(i, j int) bool {
+		return lst[i].typeName < lst[j].typeName
+	})
+	scratch := make(map[string]string)
+	for _, v := range lst {
+		makeTableStructStuff(v, scratch)
+	}
+	if fatal {
+		return fmt.Errorf("failed to parse tables")
+	}
+
+	output := make(map[string]string)
+	addImport(output, "strings")
+	body := makeLintChecks(lst, output)
+	src := fmt.Sprintf(lintFile, output["imports"], *rtPkg, body)
+	if err := os.WriteFile(lintHarness, StringBytes(src), 0o644); err != nil {
+		return err
+	}
+	defer os.Remove(lintHarness)
+
+	report, err := os.CreateTemp("", "tablegen-lint-*.json")
+	if err != nil {
+		return err
+	}
+	report.Close()
+	defer os.Remove(report.Name())
+
+	cmd := exec.Command("go", "test", "-count=1", "-run", "^TestTableGenLint$", ".")
+	cmd.Env = append(os.Environ(), "TABLEGEN_LINT_DATA="+dataDir, "TABLEGEN_LINT_OUT="+report.Name(), "TABLEGEN_LINT_LAYERS="+*layers,
+		"TABLEGEN_LINT_LANG="+*lang, "TABLEGEN_LINT_LOCALES="+*locales)
+	if b, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("go test failed: %v\n%s", err, b)
+	}
+
+	b, err := os.ReadFile(report.Name())
+	if err != nil {
+		return err
+	}
+	var r struct {
+		Errors   int
+		Warnings int
+	}
+	if err := json.Unmarshal(b, &r); err != nil {
+		return fmt.Errorf("bad lint report: %v", err)
+	}
+	if *out != "" {
+		err = os.WriteFile(*out, b, 0o644)
+	} else {
+		_, err = os.Stdout.Write(b)
+	}
+	if err != nil {
+		return err
+	}
+	if r.Errors > 0 {
+		return fmt.Errorf("%d error(s), %d warning(s)", r.Errors, r.Warnings)
+	}
+	return nil
+}
+
+// makeLintChecks 生成测试中依次执行的检查：引用、唯一性、孤立行、@check函数
+func makeLintChecks(lst []*TableStruct, output map[string]string) string {
+	checks := make([]string, 0)
+	targets := make([]string, 0)
+	for _, ts := range lst {
+		for _, f := range columnFields(ts) {
+			if target, ok := f.attrs["ref"]; ok {
+				checks = append(checks, lintRef(ts, f, target, output))
+				if name := tables[strings.ToLower(target)].typeName; !slices.Contains(targets, name) {
+					targets = append(targets, name)
+				}
+			}
+			if f.hasAttr("unique") {
+				if f.vtype != nil && !types.Comparable(f.vtype) {
+					log.Fatalf("%s.%s: unique needs a comparable field", ts.typeName, f.name)
+				}
+				checks = append(checks, fmt.Sprintf(lintUnique, ts.typeName, f.name, ts.typeName, ts.typeName, typeString(f, output), f.name))
+			}
+		}
+	}
+	sort.Strings(targets)
+	for _, v := range targets {
+		checks = append(checks, fmt.Sprintf(lintOrphans, v, v))
+	}
+	checks = append(checks, lintFuncs()...)
+	return strings.Join(checks, "\n\t\t")
+}
+
+// lintRef 生成 gtable:"ref=Table" 的检查，字段可以是单个值或切片
+func lintRef(ts *TableStruct, f *TableField, target string, output map[string]string) string {
+	t, ok := tables[strings.ToLower(target)]
+	if !ok {
+		log.Fatalf("%s.%s: ref table %s not found", ts.typeName, f.name, target)
+	}
+	if len(t.keyField) != 1 {
+		log.Fatalf("%s.%s: ref table %s must have a single key", ts.typeName, f.name, target)
+	}
+	key := t.mapKeyType
+	if f.vtype == nil {
+		log.Fatalf("%s.%s: can not resolve type %s", ts.typeName, f.name, f.typ)
+	}
+	elem := f.vtype
+	values := fmt.Sprintf("[]%s{%s(p.%s)}", key, key, f.name)
+	if sl, ok := f.vtype.Underlying().(*types.Slice); ok {
+		elem = sl.Elem()
+		values = fmt.Sprintf(lintRefSlice, key, key, f.name, f.name, key)
+	}
+	// 主键只有int和string，引用字段需为同类的基础类型
+	info := types.IsInteger
+	if key == "string" {
+		info = types.IsString
+	}
+	if b, ok := elem.Underlying().(*types.Basic); !ok || b.Info()&info == 0 {
+		log.Fatalf("%s.%s: type %s can not refer to %s key %s", ts.typeName, f.name, f.vtype, target, key)
+	}
+	return fmt.Sprintf(lintRefs, ts.typeName, f.name, ts.typeName, ts.typeName, key, values, t.typeName, t.typeName)
+}
+
+// lintFuncs 查找c_文件中 @check 标注的函数，签名为 func() error 或 func() []error
+func lintFuncs() []string {
+	src := loadSource()
+	lst := make([]string, 0)
+	for _, file := range src.cFiles() {
+		for _, decl := range file.Decls {
+			fd, ok := decl.(*ast.FuncDecl)
+			if !ok || fd.Recv != nil || fd.Doc == nil || !strings.Contains(fd.Doc.Text(), "@check") {
+				continue
+			}
+			pos := src.fset.Position(fd.Pos())
+			fn, _ := src.info.Defs[fd.Name].(*types.Func)
+			if fn == nil {
+				log.Fatalf("%s: can not resolve @check func %s", pos, fd.Name.Name)
+			}
+			sig := fn.Type().(*types.Signature)
+			if sig.Params().Len() != 0 || sig.Results().Len() != 1 {
+				log.Fatalf("%s: @check func %s must be func() error or func() []error", pos, fd.Name.Name)
+			}
+			switch types.TypeString(sig.Results().At(0).Type(), nil) {
+			case "error":
+				lst = append(lst, fmt.Sprintf(lintFunc, fd.Name.Name, fd.Name.Name, ""))
+			case "[]error":
+				lst = append(lst, fmt.Sprintf(lintFunc, fd.Name.Name, fd.Name.Name, "..."))
+			default:
+				log.Fatalf("%s: @check func %s must be func() error or func() []error", pos, fd.Name.Name)
+			}
+		}
+	}
+	return lst
+}
@@ -0,0 +1,335 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"go/format"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/colakuma/server-tool/table-gen/gtrt"
+)
+
+// typeAlias 类型行中允许的写法，对应生成的Go类型
+var typeAlias = map[string]string{
+	"int": "int", "int8": "int8", "int16": "int16", "int32": "int32", "int64": "int64",
+	"uint": "uint", "uint8": "uint8", "uint16": "uint16", "uint32": "uint32", "uint64": "uint64",
+	"float32": "float32", "float64": "float64", "float": "float64", "double": "float64",
+	"long": "int64", "bool": "bool", "string": "string", "str": "string",
+}
+
+// newColumn 新表的一列
+type newColumn struct {
+	header string
+	field  string
+	typ    string
+	key    bool
+}
+
+// runNew table-gen new: 由csv或xlsx的表头生成c_xxx.go结构体定义
+func runNew(args []string) error {
+	fs := flag.NewFlagSet("new", flag.ExitOnError)
+	from := fs.String("from", "", "csv or xlsx file, xlsx may be file.xlsx:Sheet")
+	typeName := fs.String("type", "", "struct name, defaults to the file name")
+	keys := fs.String("key", "", "comma separated key columns, defaults to Id or the first column")
+	force := fs.Bool("force", false, "overwrite an existing c_ file")
+	fs.Parse(args)
+	if *from == "" {
+		return fmt.Errorf("-from is required")
+	}
+
+	file, sheet, _ := strings.Cut(*from, ":")
+	base := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+	if *typeName == "" {
+		*typeName = goIdent(base)
+	}
+	if *typeName == "" {
+		return fmt.Errorf("can not derive a type name from %s, use -type", file)
+	}
+	out := "c_" + strings.ToLower(base) + ".go"
+	if _, err := os.Stat(out); err == nil && !*force {
+		return fmt.Errorf("%s already exists, use -force to overwrite", out)
+	}
+
+	var rows [][]string
+	var err error
+	if strings.EqualFold(filepath.Ext(file), ".xlsx") {
+		rows, err = readXlsxRows(file, sheet)
+	} else {
+		rows, err = readCSVRows(file)
+	}
+	if err != nil {
+		return err
+	}
+	cols, typeRow, err := newColumns(rows, *keys)
+	if err != nil {
+		return fmt.Errorf("%s: %w", file, err)
+	}
+	// 不是注释行的类型行会被当作数据加载，csv改写为注释行，xlsx在导出时去掉
+	if typeRow >= 0 {
+		if strings.EqualFold(filepath.Ext(file), ".xlsx") {
+			fmt.Printf("%s: the type row is dropped when exported by table-gen xlsx\n", *from)
+		} else {
+			rows[typeRow][0] = "#" + rows[typeRow][0]
+			if err := writeCSV(file, rows); err != nil {
+				return err
+			}
+			fmt.Printf("%s: type row converted to a # comment row\n", file)
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("package gtable\n\n/*\n")
+	fmt.Fprintf(&buf, "@%s\n", *typeName)
+	fmt.Fprintf(&buf, "@csv %s.csv\n", base)
+	if !strings.EqualFold(filepath.Ext(file), ".csv") {
+		fmt.Fprintf(&buf, "@excel %s\n", filepath.Base(*from))
+	}
+	fmt.Fprintf(&buf, "*/\ntype %s struct {\n", *typeName)
+	for _, v := range cols {
+		var attrs []string
+		if v.key {
+			attrs = append(attrs, "key")
+		}
+		if !strings.EqualFold(v.field, v.header) {
+			attrs = append(attrs, "col="+v.header)
+		}
+		if len(attrs) > 0 {
+			fmt.Fprintf(&buf, "\t%s %s `gtable:\"%s\"`\n", v.field, v.typ, strings.Join(attrs, ","))
+		} else {
+			fmt.Fprintf(&buf, "\t%s %s\n", v.field, v.typ)
+		}
+	}
+	buf.WriteString("}\n")
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(out, src, 0o644); err != nil {
+		return err
+	}
+	fmt.Printf("%s written, run table-gen to generate the table code\n", out)
+	return nil
+}
+
+func readCSVRows(file string) ([][]string, error) {
+	rows, _, err := readCSVLines(file)
+	return rows, err
+}
+
+// readCSVLines 读取csv的所有行，同时返回每行在文件中的起始行号，
+// 空行和多行的引号单元格会使行号与行的下标不同
+func readCSVLines(file string) ([][]string, []int, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, nil, err
+	}
+	r := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\ufeff"))))
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	var rows [][]string
+	var lines []int
+	for {
+		row, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return rows, lines, nil
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		line, _ := r.FieldPos(0)
+		rows = append(rows, row)
+		lines = append(lines, line)
+	}
+}
+
+func readXlsxRows(file, sheet string) ([][]string, error) {
+	b, err := openXlsx(file)
+	if err != nil {
+		return nil, err
+	}
+	defer b.Close()
+	return b.readSheet(sheet)
+}
+
+// newColumns 解析表头、可选的类型行和数据，类型行缺失时按数据推断类型，
+// 类型行不是注释行时返回它在rows中的位置，否则为-1
+func newColumns(rows [][]string, keys string) ([]*newColumn, int, error) {
+	hi := 0
+	for hi < len(rows) && gtrt.IsCommentRow(rows[hi]) {
+		hi++
+	}
+	if hi == len(rows) {
+		return nil, -1, fmt.Errorf("no header row")
+	}
+
+	cols := make([]*newColumn, 0, len(rows[hi]))
+	idx := make([]int, 0, len(rows[hi]))
+	used := make(map[string]bool)
+	for i, v := range rows[hi] {
+		h, ok := normalizeHeader(v)
+		if !ok {
+			continue
+		}
+		for _, c := range cols {
+			if strings.EqualFold(c.header, h) {
+				return nil, -1, fmt.Errorf("duplicate column %s", h)
+			}
+		}
+		field := goIdent(h)
+		if field == "" {
+			field = fmt.Sprintf("Col%d", i+1)
+		}
+		for n := 2; used[strings.ToLower(field)]; n++ {
+			field = fmt.Sprintf("%s%d", goIdent(h), n)
+		}
+		used[strings.ToLower(field)] = true
+		cols = append(cols, &newColumn{header: h, field: field})
+		idx = append(idx, i)
+	}
+	if len(cols) == 0 {
+		return nil, -1, fmt.Errorf("header row has no columns")
+	}
+
+	data := rows[hi+1:]
+	// 类型行可以写成注释行，如 #int,string，这样加载时会被跳过
+	ti := -1
+	if len(data) > 0 {
+		if types, ok := typeRow(data[0], idx); ok {
+			if !gtrt.IsCommentRow(data[0]) {
+				ti = hi + 1
+			}
+			for i, v := range cols {
+				v.typ = types[i]
+			}
+			data = data[1:]
+		}
+	}
+	for i, v := range cols {
+		if v.typ == "" {
+			v.typ = inferType(data, idx[i])
+		}
+	}
+
+	if keys == "" {
+		keys = cols[0].field
+		for _, v := range cols {
+			if strings.EqualFold(v.field, defaultKeyName) {
+				keys = v.field
+				break
+			}
+		}
+	}
+	for _, k := range strings.Split(keys, ",") {
+		k = strings.TrimSpace(k)
+		found := false
+		for _, v := range cols {
+			if strings.EqualFold(v.field, k) || strings.EqualFold(v.header, k) {
+				v.key, found = true, true
+			}
+		}
+		if !found {
+			return nil, -1, fmt.Errorf("key column %s not found", k)
+		}
+	}
+	// 主键只支持int和string，浮点数和布尔值不能当作字符串处理，需在类型行中写明
+	for _, v := range cols {
+		if !v.key || v.typ == "int" || v.typ == "string" {
+			continue
+		}
+		if !strings.Contains(v.typ, "int") {
+			return nil, -1, fmt.Errorf("key column %s is %s, keys must be int or string, declare the type in a type row", v.header, v.typ)
+		}
+		v.typ = "int"
+	}
+	return cols, ti, nil
+}
+
+// typeRow 判断row是否为类型行，每个非空单元格都是已知类型才算
+func typeRow(row []string, idx []int) ([]string, bool) {
+	types := make([]string, len(idx))
+	n := 0
+	for i, c := range idx {
+		if c >= len(row) {
+			continue
+		}
+		v := strings.ToLower(strings.TrimSpace(row[c]))
+		if i == 0 && c == 0 {
+			v = strings.TrimLeft(strings.TrimPrefix(v, "//"), "# ")
+		}
+		if v == "" {
+			continue
+		}
+		typ, ok := typeAlias[v]
+		if !ok {
+			return nil, false
+		}
+		types[i] = typ
+		n++
+	}
+	return types, n > 0
+}
+
+// inferType 由数据推断列类型：全是整数为int，全是数字为float64，全是true/false为bool，否则为string
+func inferType(rows [][]string, col int) string {
+	isInt, isFloat, isBool, seen := true, true, true, false
+	for _, row := range rows {
+		if gtrt.IsCommentRow(row) || col >= len(row) {
+			continue
+		}
+		v := strings.TrimSpace(row[col])
+		if v == "" {
+			continue
+		}
+		seen = true
+		if _, err := strconv.ParseInt(v, 10, 64); err != nil {
+			isInt = false
+		}
+		if _, err := strconv.ParseFloat(v, 64); err != nil {
+			isFloat = false
+		}
+		if l := strings.ToLower(v); l != "true" && l != "false" {
+			isBool = false
+		}
+	}
+	switch {
+	case !seen:
+		return "string"
+	case isInt:
+		return "int"
+	case isFloat:
+		return "float64"
+	case isBool:
+		return "bool"
+	}
+	return "string"
+}
+
+// goIdent 将列名转换为导出的Go标识符，如 item_id -> ItemId，无法转换时返回空
+func goIdent(s string) string {
+	var sb strings.Builder
+	upper := true
+	for _, c := range s {
+		if c > unicode.MaxASCII || !(unicode.IsLetter(c) || unicode.IsDigit(c)) {
+			upper = true
+			continue
+		}
+		if sb.Len() == 0 && unicode.IsDigit(c) {
+			sb.WriteByte('F')
+		}
+		if upper {
+			c = unicode.ToUpper(c)
+			upper = false
+		}
+		sb.WriteRune(c)
+	}
+	return sb.String()
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// csvRows 由 "Id,Name" 形式的行构造rows
+func csvRows(lines ...string) [][]string {
+	rows := make([][]string, 0, len(lines))
+	for _, v := range lines {
+		rows = append(rows, strings.Split(v, ","))
+	}
+	return rows
+}
+
+func TestNewColumns(t *testing.T) {
+	tests := []struct {
+		name    string
+		rows    [][]string
+		keys    string
+		types   string // 按列的类型，逗号分隔
+		key     string
+		typeRow int
+		err     string
+	}{
+		{name: "infer", rows: csvRows("Id,Name,Rate,On", "1,a,1.5,true", "2,b,2,false"), types: "int,string,float64,bool", key: "Id", typeRow: -1},
+		{name: "comment type row", rows: csvRows("Id,Name", "#int64,str", "1,a"), types: "int,string", key: "Id", typeRow: -1},
+		{name: "data type row", rows: csvRows("# note", "Id,Name", "int,string", "1,a"), types: "int,string", key: "Id", typeRow: 2},
+		{name: "first column is key", rows: csvRows("Code,Name", "a,b"), types: "string,string", key: "Code", typeRow: -1},
+		{name: "float key", rows: csvRows("Ver,Name", "1.5,a"), keys: "Ver", err: "key column Ver is float64"},
+		{name: "declared string key", rows: csvRows("Ver,Name", "#string,string", "1.5,a"), keys: "Ver", types: "string,string", key: "Ver", typeRow: -1},
+		{name: "duplicate column", rows: csvRows("Id,id"), err: "duplicate column id"},
+		{name: "missing key", rows: csvRows("Id,Name"), keys: "Code", err: "key column Code not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cols, typeRow, err := newColumns(tt.rows, tt.keys)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("err = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			types, keys := make([]string, 0, len(cols)), make([]string, 0)
+			for _, v := range cols {
+				types = append(types, v.typ)
+				if v.key {
+					keys = append(keys, v.field)
+				}
+			}
+			if got := strings.Join(types, ","); got != tt.types {
+				t.Errorf("types = %s, want %s", got, tt.types)
+			}
+			if got := strings.Join(keys, ","); got != tt.key {
+				t.Errorf("keys = %s, want %s", got, tt.key)
+			}
+			if typeRow != tt.typeRow {
+				t.Errorf("type row = %d, want %d", typeRow, tt.typeRow)
+			}
+		})
+	}
+}
+
+func TestNormalizeSheet(t *testing.T) {
+	rows := csvRows("# comment", "Id,#note,Name", "int,,string", "1,x,a", "2,y,int")
+	got := normalizeSheet(rows)
+	want := csvRows("Id,Name", "1,a", "2,int")
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if strings.Join(got[i], ",") != strings.Join(want[i], ",") {
+			t.Errorf("row %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/colakuma/server-tool/table-gen/gtrt"
+)
+
+// runXlsx table-gen xlsx: 按@excel注解读取工作簿，导出为@csv文件
+func runXlsx(args []string) error {
+	fs := flag.NewFlagSet("xlsx", flag.ExitOnError)
+	in := fs.String("in", ".", "directory of the xlsx workbooks")
+	out := fs.String("out", "", "directory of the generated csv files, defaults to -in")
+	fs.Parse(args)
+	if *out == "" {
+		*out = *in
+	}
+
+	files, _ := enumFile(".", "c_")
+	for _, v := range files {
+		walkFile(v)
+	}
+
+	// 按工作簿分组，同一工作簿只打开一次
+	books := make(map[string][]xlsxJob)
+	for _, v := range tables {
+		jobs, err := xlsxJobs(v, *in)
+		if err != nil {
+			return err
+		}
+		for _, j := range jobs {
+			books[j.file] = append(books[j.file], j)
+		}
+	}
+	names := make([]string, 0, len(books))
+	for k := range books {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	failed := 0
+	for _, file := range names {
+		lst := books[file]
+		sort.Slice(lst, func(i, j int) bool {
+			return lst[i].ts.typeName < lst[j].ts.typeName
+		})
+		n, err := exportBook(*in, file, *out, lst)
+		if err != nil {
+			log.Printf("%v", err)
+		}
+		failed += n
+	}
+	reportUnusedBooks(*in, books)
+
+	if failed > 0 {
+		return fmt.Errorf("%d sheet(s) failed", failed)
+	}
+	return nil
+}
+
+// xlsxJob 一个工作表导出为一个csv文件
+type xlsxJob struct {
+	ts    *TableStruct
+	file  string
+	sheet string
+	csv   string
+}
+
+// xlsxJobs 展开表的@excel，@csv与@excel一一对应时按顺序配对，否则由工作簿名推导csv名
+func xlsxJobs(ts *TableStruct, dir string) ([]xlsxJob, error) {
+	pair := len(ts.csv) == len(ts.excel)
+	lst := make([]xlsxJob, 0, len(ts.excel))
+	for i, v := range ts.excel {
+		file, sheet := excelSheet(v)
+		if !strings.ContainsAny(file, `*?[`) {
+			csvName := gtrt.CSVName(v, "")
+			if pair {
+				csvName = ts.csv[i]
+			}
+			lst = append(lst, xlsxJob{ts: ts, file: file, sheet: sheet, csv: csvName})
+			continue
+		}
+		matches, err := filepath.Glob(filepath.Join(dir, file))
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			log.Printf("table %s: %s matches no workbook", ts.typeName, file)
+		}
+		for _, m := range matches {
+			rel, _ := filepath.Rel(dir, m)
+			lst = append(lst, xlsxJob{ts: ts, file: rel, sheet: sheet, csv: gtrt.CSVName(rel, "")})
+		}
+	}
+	return lst, nil
+}
+
+// exportBook 导出一个工作簿中被表引用的工作表，返回失败的数量
+func exportBook(dir, file, out string, lst []xlsxJob) (int, error) {
+	b, err := openXlsx(filepath.Join(dir, file))
+	if err != nil {
+		return len(lst), err
+	}
+	defer b.Close()
+
+	used := make(map[string]bool)
+	failed := 0
+	for _, job := range lst {
+		sheet := job.sheet
+		if sheet == "" && len(b.sheets) > 0 {
+			sheet = b.sheets[0].name
+		}
+		used[sheet] = true
+		rows, err := b.readSheet(sheet)
+		if err == nil {
+			err = writeCSV(filepath.Join(out, job.csv), normalizeSheet(rows))
+		}
+		if err != nil {
+			log.Printf("%s: table %s: %v", file, job.ts.typeName, err)
+			failed++
+			continue
+		}
+		fmt.Printf("%s:%s -> %s\n", file, sheet, job.csv)
+	}
+	for _, v := range b.sheetNames() {
+		if !used[v] {
+			fmt.Printf("%s: sheet %s maps to no table\n", file, v)
+		}
+	}
+	return failed, nil
+}
+
+// reportUnusedBooks 报告输入目录中没有任何表引用的工作簿
+func reportUnusedBooks(dir string, books map[string][]xlsxJob) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, v := range entries {
+		name := v.Name()
+		// ~$开头的是Excel打开时的锁文件
+		if v.IsDir() || !strings.EqualFold(filepath.Ext(name), ".xlsx") || strings.HasPrefix(name, "~$") {
+			continue
+		}
+		if _, ok := books[name]; ok {
+			continue
+		}
+		b, err := openXlsx(filepath.Join(dir, name))
+		if err != nil {
+			log.Printf("%v", err)
+			continue
+		}
+		for _, s := range b.sheetNames() {
+			fmt.Printf("%s: sheet %s maps to no table\n", name, s)
+		}
+		b.Close()
+	}
+}
+
+// normalizeSheet 去掉注释行和注释列，第一行作为表头，紧跟表头的类型行也去掉
+func normalizeSheet(rows [][]string) [][]string {
+	out := make([][]string, 0, len(rows))
+	var cols []int
+	for _, row := range rows {
+		if gtrt.IsCommentRow(row) {
+			continue
+		}
+		if len(out) == 1 {
+			if _, ok := typeRow(row, cols); ok {
+				continue
+			}
+		}
+		if cols == nil {
+			header := make([]string, 0, len(row))
+			cols = make([]int, 0, len(row))
+			for i, v := range row {
+				v, ok := normalizeHeader(v)
+				if !ok {
+					continue
+				}
+				cols = append(cols, i)
+				header = append(header, v)
+			}
+			out = append(out, header)
+			continue
+		}
+		line := make([]string, len(cols))
+		for i, c := range cols {
+			if c < len(row) {
+				line[i] = row[c]
+			}
+		}
+		out = append(out, line)
+	}
+	return out
+}
+
+// normalizeHeader 表头只保留单元格第一行文本，空表头和以#或//开头的列视为注释列
+func normalizeHeader(v string) (string, bool) {
+	v, _, _ = strings.Cut(v, "\n")
+	v = strings.TrimSpace(v)
+	if v == "" || strings.HasPrefix(v, "#") || strings.HasPrefix(v, "//") {
+		return "", false
+	}
+	return v, true
+}
+
+func writeCSV(file string, rows [][]string) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		return err
+	}
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(f)
+	w.WriteAll(rows)
+	if err := w.Error(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
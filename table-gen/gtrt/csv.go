@@ -0,0 +1,295 @@
+package gtrt
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Table 读取后的CSV内容，Lines为每行数据在文件中的行号，
+// Layer非空时为该覆盖层的覆盖文件
+type Table struct {
+	File   string
+	Layer  string
+	Header []string
+	Rows   [][]string
+	Lines  []int
+}
+
+// CellError 单元格解析错误
+type CellError struct {
+	File   string
+	Line   int
+	Column string
+	Err    error
+}
+
+func (e *CellError) Error() string {
+	if e.Column == "" {
+		return fmt.Sprintf("%s:%d: %v", e.File, e.Line, e.Err)
+	}
+	return fmt.Sprintf("%s:%d column %s: %v", e.File, e.Line, e.Column, e.Err)
+}
+
+func (e *CellError) Unwrap() error {
+	return e.Err
+}
+
+// ErrRequired 必填单元格为空
+var ErrRequired = errors.New("required cell is empty")
+
+// CellOr 返回第idx列的单元格，没有该列或单元格为空时返回def
+func CellOr(row []string, idx int, def string) string {
+	if idx < 0 || idx >= len(row) || strings.TrimSpace(row[idx]) == "" {
+		return def
+	}
+	return row[idx]
+}
+
+// CSVName 返回表对应的csv文件名，未配置@csv时由@excel文件名推导，
+// @excel 可以是 file.xlsx:Sheet 的形式
+func CSVName(excel, csvName string) string {
+	if csvName == "" {
+		excel, _, _ = strings.Cut(excel, ":")
+		csvName = strings.TrimSuffix(excel, filepath.Ext(excel))
+	}
+	if filepath.Ext(csvName) == "" {
+		csvName += ".csv"
+	}
+	return csvName
+}
+
+// IsCommentRow 空行和以#或//开头的行不是数据
+func IsCommentRow(row []string) bool {
+	if len(row) == 0 {
+		return true
+	}
+	first := strings.TrimSpace(row[0])
+	if strings.HasPrefix(first, "#") || strings.HasPrefix(first, "//") {
+		return true
+	}
+	for _, v := range row {
+		if strings.TrimSpace(v) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// ReadTable 读取DataDir下的csv文件，第一个数据行为表头
+func ReadTable(name string) (*Table, error) {
+	path := filepath.Join(dataDir, name)
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	t := &Table{File: name}
+	for {
+		row, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		if IsCommentRow(row) {
+			continue
+		}
+		line, _ := r.FieldPos(0)
+		if t.Header == nil {
+			row[0] = strings.TrimPrefix(row[0], "\ufeff")
+			for i, v := range row {
+				row[i] = strings.TrimSpace(v)
+			}
+			t.Header = row
+			continue
+		}
+		for len(row) < len(t.Header) {
+			row = append(row, "")
+		}
+		t.Rows = append(t.Rows, row)
+		t.Lines = append(t.Lines, line)
+	}
+	if t.Header == nil {
+		return nil, fmt.Errorf("%s: missing header", name)
+	}
+	return t, nil
+}
+
+// RowError 包装第i行数据的错误，补充文件名和行号
+func (t *Table) RowError(i int, err error) error {
+	ce := &CellError{}
+	if errors.As(err, &ce) {
+		return &CellError{File: t.File, Line: t.Lines[i], Column: ce.Column, Err: ce.Err}
+	}
+	return &CellError{File: t.File, Line: t.Lines[i], Err: err}
+}
+
+// BindColumns 返回各字段在表头中的列下标(忽略大小写)，缺列为-1
+func BindColumns(header []string, names ...string) []int {
+	idx := make([]int, len(names))
+	for i, name := range names {
+		idx[i] = -1
+		for j, v := range header {
+			if strings.EqualFold(v, name) {
+				idx[i] = j
+				break
+			}
+		}
+	}
+	return idx
+}
+
+// Columns 生成代码按表头绑定列的结果。
+// Parse的base非nil时在base的副本上解析，表头中没有的列保留base的值，用于覆盖层的替换；
+// Key只解析主键列，用于覆盖层的删除
+type Columns[T any] struct {
+	Parse      func(row []string, base *T) (*T, error)
+	Key        func(row []string) (*T, error)
+	Missing    []string // 表头中缺少且没有默认值的列
+	MissingKey []string // 表头中缺少的主键列
+}
+
+// MissingColumns 返回表头中没有的列(忽略大小写)
+func MissingColumns(header []string, names ...string) []string {
+	var lst []string
+	for i, v := range BindColumns(header, names...) {
+		if v < 0 {
+			lst = append(lst, names[i])
+		}
+	}
+	return lst
+}
+
+// LoadRows 用生成的parse和key函数逐行解析t写入m，主键重复时报告两处行号
+func LoadRows[K comparable, T any](t *Table, m map[K]*T, parse func([]string) (*T, error), key func(*T) K) error {
+	bind := func([]string) Columns[T] {
+		return Columns[T]{Parse: func(row []string, _ *T) (*T, error) { return parse(row) }, Key: parse}
+	}
+	_, err := LoadTables([]*Table{t}, m, bind, key)
+	return err
+}
+
+// LoadTables 依次解析多个文件写入m，每个文件按自己的表头绑定列，缺少没有默认值的列时报错，
+// 主键重复时报告两处的文件和行号。覆盖层的文件在基础表之后按主键合并，只要求有主键列，
+// 返回合并时对每行的操作
+func LoadTables[K comparable, T any](ts []*Table, m map[K]*T, bind func(header []string) Columns[T], key func(*T) K) ([]LayerOp, error) {
+	type origin struct {
+		file string
+		line int
+	}
+	first := make(map[K]origin)
+	var ops []LayerOp
+	for _, t := range ts {
+		c := bind(t.Header)
+		if t.Layer != "" {
+			if len(c.MissingKey) > 0 {
+				return nil, t.missingError(c.MissingKey)
+			}
+			lst, err := applyLayer(t, m, c, key)
+			if err != nil {
+				return nil, err
+			}
+			ops = append(ops, lst...)
+			continue
+		}
+		if len(c.Missing) > 0 {
+			return nil, t.missingError(c.Missing)
+		}
+		for i, row := range t.Rows {
+			v, err := c.Parse(row, nil)
+			if err != nil {
+				return nil, t.RowError(i, err)
+			}
+			k := key(v)
+			if o, ok := first[k]; ok {
+				return nil, t.RowError(i, fmt.Errorf("duplicate key %v, first defined at %s:%d", k, o.file, o.line))
+			}
+			first[k] = origin{file: t.File, line: t.Lines[i]}
+			m[k] = v
+		}
+	}
+	return ops, nil
+}
+
+func (t *Table) missingError(names []string) error {
+	return fmt.Errorf("%s: column %s not found in header", t.File, strings.Join(names, ", "))
+}
+
+// ReadTables 读取多个数据文件，名字可以是glob，glob的结果按文件名排序，
+// 没有匹配任何文件的glob视为错误。之后依次是各覆盖层中存在的同名文件
+func ReadTables(names ...string) ([]*Table, error) {
+	files, err := expandFiles(names)
+	if err != nil {
+		return nil, err
+	}
+	ts := make([]*Table, 0, len(files))
+	for _, v := range files {
+		t, err := ReadTable(v)
+		if err != nil {
+			return nil, err
+		}
+		ts = append(ts, t)
+	}
+	lts, err := readLayers(files)
+	if err != nil {
+		return nil, err
+	}
+	return append(ts, lts...), nil
+}
+
+// expandFiles 展开DataDir下的glob，返回相对DataDir的文件名，重复的只保留一个
+func expandFiles(names []string) ([]string, error) {
+	lst := make([]string, 0, len(names))
+	for _, name := range names {
+		if !hasMeta(name) {
+			if !slices.Contains(lst, name) {
+				lst = append(lst, name)
+			}
+			continue
+		}
+		matches, err := filepath.Glob(filepath.Join(dataDir, name))
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("%s: no file matches", name)
+		}
+		sort.Strings(matches)
+		for _, m := range matches {
+			rel, err := filepath.Rel(dataDir, m)
+			if err != nil {
+				return nil, err
+			}
+			if !slices.Contains(lst, rel) {
+				lst = append(lst, rel)
+			}
+		}
+	}
+	return lst, nil
+}
+
+func hasMeta(name string) bool {
+	return strings.ContainsAny(name, `*?[\`)
+}
+
+// matchFile 判断file是否为登记的数据文件pattern，pattern可以是glob，
+// file可以是相对DataDir的路径或只有文件名
+func matchFile(pattern, file string) bool {
+	if ok, _ := filepath.Match(pattern, file); ok {
+		return true
+	}
+	ok, _ := filepath.Match(filepath.Base(pattern), filepath.Base(file))
+	return ok
+}
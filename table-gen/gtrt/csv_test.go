@@ -0,0 +1,221 @@
+package gtrt
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+type testRow struct {
+	id   int
+	name string
+}
+
+// parseTestRow 模拟生成的parseXRow，第0列为主键Id，第1列为Name
+func parseTestRow(row []string) (*testRow, error) {
+	id, err := ParseInt[int](row[0])
+	if err != nil {
+		return nil, &CellError{Column: "Id", Err: err}
+	}
+	return &testRow{id: id, name: row[1]}, nil
+}
+
+// bindTestRows 模拟生成的bindXColumns，按表头绑定Id和Name，Name有默认值
+func bindTestRows(header []string) Columns[testRow] {
+	idx := BindColumns(header, "Id", "Name")
+	key := func(row []string) (*testRow, error) {
+		id, err := ParseInt[int](row[idx[0]])
+		if err != nil {
+			return nil, &CellError{Column: "Id", Err: err}
+		}
+		return &testRow{id: id}, nil
+	}
+	parse := func(row []string, base *testRow) (*testRow, error) {
+		p, err := key(row)
+		if err != nil {
+			return nil, err
+		}
+		if base != nil {
+			p.name = base.name
+		}
+		if idx[1] >= 0 || base == nil {
+			p.name = CellOr(row, idx[1], "none")
+		}
+		return p, nil
+	}
+	return Columns[testRow]{Parse: parse, Key: key, MissingKey: MissingColumns(header, "Id")}
+}
+
+func testRowKey(p *testRow) int {
+	return p.id
+}
+
+// testTable 由 "Id,Name" 形式的行构造表，第一行为表头，行号从2开始
+func testTable(file string, lines ...string) *Table {
+	t := &Table{File: file, Header: strings.Split(lines[0], ",")}
+	for i, v := range lines[1:] {
+		row := strings.Split(v, ",")
+		for len(row) < len(t.Header) {
+			row = append(row, "")
+		}
+		t.Rows = append(t.Rows, row)
+		t.Lines = append(t.Lines, i+2)
+	}
+	return t
+}
+
+func checkRows(t *testing.T, m map[int]*testRow, want map[int]string) {
+	t.Helper()
+	if len(m) != len(want) {
+		t.Errorf("got %d rows, want %d", len(m), len(want))
+	}
+	for k, v := range want {
+		if p, ok := m[k]; !ok || p.name != v {
+			t.Errorf("row %d = %v, want name %q", k, p, v)
+		}
+	}
+}
+
+func TestLoadRows(t *testing.T) {
+	tests := []struct {
+		name  string
+		table *Table
+		want  map[int]string
+		err   string
+	}{
+		{
+			name:  "rows",
+			table: testTable("a.csv", "Id,Name", "1,x", "2,"),
+			want:  map[int]string{1: "x", 2: ""},
+		},
+		{
+			name:  "duplicate key",
+			table: testTable("a.csv", "Id,Name", "1,x", "1,y"),
+			err:   "a.csv:3: duplicate key 1, first defined at a.csv:2",
+		},
+		{
+			name:  "bad cell",
+			table: testTable("a.csv", "Id,Name", "1,x", "x,y"),
+			err:   `a.csv:3 column Id: strconv.ParseInt: parsing "x": invalid syntax`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := make(map[int]*testRow)
+			err := LoadRows(tt.table, m, parseTestRow, testRowKey)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("err = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkRows(t, m, tt.want)
+		})
+	}
+}
+
+func TestParseCells(t *testing.T) {
+	if v, err := ParseInt[int8](" 127 "); err != nil || v != 127 {
+		t.Errorf("ParseInt = %v, %v", v, err)
+	}
+	if _, err := ParseInt[int8]("128"); err == nil {
+		t.Error("ParseInt[int8](128) should overflow")
+	}
+	if v, err := ParseUint[uint16](""); err != nil || v != 0 {
+		t.Errorf("ParseUint empty = %v, %v", v, err)
+	}
+	if _, err := ParseUint[uint]("-1"); err == nil {
+		t.Error("ParseUint(-1) should fail")
+	}
+	if v, err := ParseFloat[float32]("1.5"); err != nil || v != 1.5 {
+		t.Errorf("ParseFloat = %v, %v", v, err)
+	}
+	if v, err := ParseBool[bool]("TRUE"); err != nil || !v {
+		t.Errorf("ParseBool = %v, %v", v, err)
+	}
+	if v, _ := ParseString[string](" a "); v != "a" {
+		t.Errorf("ParseString = %q", v)
+	}
+}
+
+func TestLoadTables(t *testing.T) {
+	tests := []struct {
+		name   string
+		tables []*Table
+		want   map[int]string
+		err    string
+	}{
+		{
+			name:   "multiple files",
+			tables: []*Table{testTable("a.csv", "Id,Name", "1,x", "3,"), testTable("b.csv", "Name,Id", "y,2")},
+			want:   map[int]string{1: "x", 2: "y", 3: "none"},
+		},
+		{
+			name:   "duplicate across files",
+			tables: []*Table{testTable("a.csv", "Id,Name", "1,x"), testTable("b.csv", "Id,Name", "2,y", "1,z")},
+			err:    "b.csv:3: duplicate key 1, first defined at a.csv:2",
+		},
+		{
+			name:   "bad cell in second file",
+			tables: []*Table{testTable("a.csv", "Id,Name", "1,x"), testTable("b.csv", "Id,Name", "x,y")},
+			err:    "b.csv:2 column Id:",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := make(map[int]*testRow)
+			_, err := LoadTables(tt.tables, m, bindTestRows, testRowKey)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("err = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkRows(t, m, tt.want)
+		})
+	}
+}
+
+func TestLoadTablesMissingColumn(t *testing.T) {
+	bind := func(header []string) Columns[testRow] {
+		c := bindTestRows(header)
+		c.Missing = MissingColumns(header, "Id", "Type")
+		return c
+	}
+	m := make(map[int]*testRow)
+	_, err := LoadTables([]*Table{testTable("a.csv", "Id,Name", "1,x")}, m, bind, testRowKey)
+	if err == nil || err.Error() != "a.csv: column Type not found in header" {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestExpandFiles(t *testing.T) {
+	dir := t.TempDir()
+	oldDir := dataDir
+	SetDataDir(dir)
+	t.Cleanup(func() { dataDir = oldDir })
+	for _, v := range []string{"m_2.csv", "m_1.csv", "m_10.csv", "n.csv"} {
+		if err := os.WriteFile(filepath.Join(dir, v), []byte("Id\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := expandFiles([]string{"n.csv", "m_*.csv", "m_1.csv"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"n.csv", "m_1.csv", "m_10.csv", "m_2.csv"}; !slices.Equal(got, want) {
+		t.Errorf("files = %v, want %v", got, want)
+	}
+	if _, err := expandFiles([]string{"x_*.csv"}); err == nil || err.Error() != "x_*.csv: no file matches" {
+		t.Errorf("err = %v", err)
+	}
+}
@@ -0,0 +1,130 @@
+package gtrt
+
+import (
+	"cmp"
+	"fmt"
+	"reflect"
+	"slices"
+	"sync"
+)
+
+// Change 主键相同但字段有变化的行
+type Change[K comparable] struct {
+	Key    K
+	Fields []string
+}
+
+// Diff 按主键比较的新旧表差异，各列表按主键排序。
+// Layers为新数据加载时覆盖层对各行的操作，按覆盖的先后排列
+type Diff[K comparable] struct {
+	Added   []K
+	Removed []K
+	Changed []Change[K]
+	Layers  []LayerOp
+}
+
+// Empty 新旧数据没有差异
+func (d *Diff[K]) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// DiffMaps 比较新旧表，rowDiff由生成代码提供，返回变化的字段名
+func DiffMaps[K comparable, T any](old, new map[K]*T, rowDiff func(a, b *T) []string) Diff[K] {
+	d := Diff[K]{}
+	for k, v := range new {
+		o, ok := old[k]
+		if !ok {
+			d.Added = append(d.Added, k)
+			continue
+		}
+		if fields := rowDiff(o, v); len(fields) > 0 {
+			d.Changed = append(d.Changed, Change[K]{Key: k, Fields: fields})
+		}
+	}
+	for k := range old {
+		if _, ok := new[k]; !ok {
+			d.Removed = append(d.Removed, k)
+		}
+	}
+	slices.SortFunc(d.Added, compareKey)
+	slices.SortFunc(d.Removed, compareKey)
+	slices.SortFunc(d.Changed, func(a, b Change[K]) int {
+		return compareKey(a.Key, b.Key)
+	})
+	return d
+}
+
+// compareKey 按主键排序：整数、浮点数和字符串按值比较，
+// Key2、Key3和生成的主键结构体按字段依次比较
+func compareKey[K comparable](a, b K) int {
+	switch x := any(a).(type) {
+	case int:
+		return cmp.Compare(x, any(b).(int))
+	case string:
+		return cmp.Compare(x, any(b).(string))
+	}
+	return compareValue(reflect.ValueOf(a), reflect.ValueOf(b))
+}
+
+func compareValue(a, b reflect.Value) int {
+	switch a.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return cmp.Compare(a.Int(), b.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return cmp.Compare(a.Uint(), b.Uint())
+	case reflect.Float32, reflect.Float64:
+		return cmp.Compare(a.Float(), b.Float())
+	case reflect.String:
+		return cmp.Compare(a.String(), b.String())
+	case reflect.Bool:
+		return cmp.Compare(boolInt(a.Bool()), boolInt(b.Bool()))
+	case reflect.Struct:
+		for i := range a.NumField() {
+			if c := compareValue(a.Field(i), b.Field(i)); c != 0 {
+				return c
+			}
+		}
+		return 0
+	case reflect.Array:
+		for i := range a.Len() {
+			if c := compareValue(a.Index(i), b.Index(i)); c != 0 {
+				return c
+			}
+		}
+		return 0
+	}
+	return cmp.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// Notifier 表热加载后的订阅者列表
+type Notifier[M any, K comparable] struct {
+	mu  sync.Mutex
+	fns []func(old, new M, diff Diff[K])
+}
+
+func (n *Notifier[M, K]) Subscribe(fn func(old, new M, diff Diff[K])) {
+	n.mu.Lock()
+	n.fns = append(n.fns, fn)
+	n.mu.Unlock()
+}
+
+// Notify 通知全部订阅者，没有订阅者时不计算差异
+func (n *Notifier[M, K]) Notify(old, new M, diff func() Diff[K]) {
+	n.mu.Lock()
+	fns := n.fns
+	n.mu.Unlock()
+	if len(fns) == 0 {
+		return
+	}
+	d := diff()
+	for _, fn := range fns {
+		fn(old, new, d)
+	}
+}
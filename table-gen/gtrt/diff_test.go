@@ -0,0 +1,57 @@
+package gtrt
+
+import (
+	"slices"
+	"testing"
+)
+
+type testID int64
+
+type diffRow struct {
+	name string
+}
+
+type testKey struct {
+	Region string
+	Slot   uint8
+}
+
+func TestCompareKey(t *testing.T) {
+	ids := []testID{10, 9, -1, 100}
+	slices.SortFunc(ids, compareKey)
+	if !slices.Equal(ids, []testID{-1, 9, 10, 100}) {
+		t.Errorf("named int64 keys = %v", ids)
+	}
+	k2 := []Key2{{2, 1}, {1, 10}, {1, 9}}
+	slices.SortFunc(k2, compareKey)
+	if !slices.Equal(k2, []Key2{{1, 9}, {1, 10}, {2, 1}}) {
+		t.Errorf("Key2 keys = %v", k2)
+	}
+	ks := []testKey{{"b", 1}, {"a", 10}, {"a", 9}}
+	slices.SortFunc(ks, compareKey)
+	if !slices.Equal(ks, []testKey{{"a", 9}, {"a", 10}, {"b", 1}}) {
+		t.Errorf("struct keys = %v", ks)
+	}
+	fs := []float64{10, 9.5, -2}
+	slices.SortFunc(fs, compareKey)
+	if !slices.Equal(fs, []float64{-2, 9.5, 10}) {
+		t.Errorf("float keys = %v", fs)
+	}
+}
+
+func TestDiffMaps(t *testing.T) {
+	old := map[uint32]*diffRow{9: {name: "a"}, 10: {name: "b"}, 11: {name: "c"}}
+	cur := map[uint32]*diffRow{10: {name: "x"}, 11: {name: "c"}, 100: {name: "d"}, 12: {name: "e"}}
+	d := DiffMaps(old, cur, func(a, b *diffRow) []string {
+		if a.name != b.name {
+			return []string{"Name"}
+		}
+		return nil
+	})
+	if !slices.Equal(d.Added, []uint32{12, 100}) || !slices.Equal(d.Removed, []uint32{9}) {
+		t.Errorf("added = %v, removed = %v", d.Added, d.Removed)
+	}
+	if len(d.Changed) != 1 || d.Changed[0].Key != 10 || !slices.Equal(d.Changed[0].Fields, []string{"Name"}) {
+		t.Errorf("changed = %v", d.Changed)
+	}
+}
@@ -0,0 +1,48 @@
+// Package gtrt 是table-gen生成代码依赖的运行时，
+// 提供CSV加载、复合主键、事务式加载发布和热加载。
+// 生成代码通过 EnforceVersion 在编译期校验与运行时的版本一致。
+package gtrt
+
+import (
+	"sync"
+)
+
+// Version 生成代码与运行时的接口版本，模板改动不兼容时递增
+const Version = 8
+
+// EnforceVersion 生成代码写入 EnforceVersion(N - Version) 和 EnforceVersion(Version - N)，
+// 版本不一致时常量溢出导致编译失败
+type EnforceVersion uint
+
+var (
+	dataDir = "."
+
+	// loadMu 串行化加载和热加载
+	loadMu sync.Mutex
+
+	queueMu  sync.Mutex
+	onCommit []func()
+)
+
+// SetDataDir 设置配置文件目录，需在LoadAll之前调用
+func SetDataDir(dir string) {
+	dataDir = dir
+}
+
+// DataDir 返回配置文件目录
+func DataDir() string {
+	return dataDir
+}
+
+// OnCommit 登记发布并校验通过后的回调，如生成代码的快照发布，需在init中调用
+func OnCommit(fn func()) {
+	queueMu.Lock()
+	onCommit = append(onCommit, fn)
+	queueMu.Unlock()
+}
+
+func commitHooks() []func() {
+	queueMu.Lock()
+	defer queueMu.Unlock()
+	return onCommit
+}
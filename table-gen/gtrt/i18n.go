@@ -0,0 +1,175 @@
+package gtrt
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/text/language"
+)
+
+// LanguageTable 内置语言表登记的表名，带 gtable:"i18n" 字段的表依赖它
+const LanguageTable = "Language"
+
+// textRow 语言表的一行，texts与配置的语言一一对应
+type textRow struct {
+	key   string
+	texts []string
+}
+
+// TextTable 加载后的语言表，发布后不再修改，可被快照长期持有
+type TextTable struct {
+	tags    []language.Tag
+	matcher language.Matcher
+	rows    map[string]*textRow
+}
+
+var (
+	langFile string
+	langTags []language.Tag
+	texts    Var[TextTable]
+)
+
+// SetLanguages 设置语言表文件和需要的语言，第一个语言为回退语言，需在LoadAll之前调用。
+// 语言表第一列为文本键，其余列名为BCP 47语言标签，如 Key,zh-CN,en,ja
+func SetLanguages(file string, locales ...string) error {
+	if len(locales) == 0 {
+		return errors.New("no locale configured")
+	}
+	tags := make([]language.Tag, 0, len(locales))
+	for _, v := range locales {
+		tag, err := language.Parse(v)
+		if err != nil {
+			return fmt.Errorf("bad locale %q: %w", v, err)
+		}
+		tags = append(tags, tag)
+	}
+	langFile, langTags = file, tags
+
+	tablesMu.Lock()
+	defer tablesMu.Unlock()
+	if t, ok := tables[LanguageTable]; ok {
+		t.Files = []string{file}
+	}
+	return nil
+}
+
+// RegisterLanguageTable 登记语言表，有 gtable:"i18n" 字段时生成代码在init中调用
+func RegisterLanguageTable() {
+	files := []string(nil)
+	if langFile != "" {
+		files = []string{langFile}
+	}
+	RegisterTable(TableInfo{Name: LanguageTable, Files: files, Load: loadLanguages})
+}
+
+func loadLanguages(tx *Tx) error {
+	if langFile == "" {
+		return errors.New("language table is not set, call gtrt.SetLanguages before LoadAll")
+	}
+	ts, err := ReadTables(langFile)
+	if err != nil {
+		return err
+	}
+	tags := langTags
+	bind := func(header []string) Columns[textRow] {
+		idx := make([]int, len(tags))
+		var missing []string
+		for i, tag := range tags {
+			idx[i] = -1
+			for j, v := range header[1:] {
+				if t, err := language.Parse(v); err == nil && t == tag {
+					idx[i] = j + 1
+					break
+				}
+			}
+			if idx[i] < 0 {
+				missing = append(missing, tag.String())
+			}
+		}
+		key := func(row []string) (*textRow, error) {
+			r := &textRow{key: strings.TrimSpace(row[0]), texts: make([]string, len(tags))}
+			if r.key == "" {
+				return nil, errors.New("empty text key")
+			}
+			return r, nil
+		}
+		parse := func(row []string, base *textRow) (*textRow, error) {
+			r, err := key(row)
+			if err != nil {
+				return nil, err
+			}
+			for i, j := range idx {
+				if j < 0 && base != nil {
+					r.texts[i] = base.texts[i]
+				} else {
+					r.texts[i] = CellOr(row, j, "")
+				}
+			}
+			return r, nil
+		}
+		return Columns[textRow]{Parse: parse, Key: key, Missing: missing}
+	}
+	rows := make(map[string]*textRow)
+	if _, err := LoadTables(ts, rows, bind, func(r *textRow) string { return r.key }); err != nil {
+		return err
+	}
+	t := &TextTable{tags: tags, matcher: language.NewMatcher(tags), rows: rows}
+	texts.Stage(tx, t)
+	tx.AppendAfterLoad(func() {
+		texts.Store(t)
+	})
+	return nil
+}
+
+// Texts 返回当前的语言表，未加载时返回nil
+func Texts() *TextTable {
+	return texts.Load()
+}
+
+// Text 返回文本键在lang下的文本，按语言匹配规则选择最接近的语言，
+// 该语言没有文本时用回退语言，文本键不存在时返回键本身
+func Text(key string, lang language.Tag) string {
+	return texts.Load().Text(key, lang)
+}
+
+// Text 同 gtrt.Text，读取的是t而不是当前的语言表，t为nil时返回键本身
+func (t *TextTable) Text(key string, lang language.Tag) string {
+	if t == nil {
+		return key
+	}
+	r, ok := t.rows[key]
+	if !ok {
+		return key
+	}
+	_, i, conf := t.matcher.Match(lang)
+	if conf == language.No {
+		i = 0
+	}
+	if r.texts[i] != "" {
+		return r.texts[i]
+	}
+	return r.texts[0]
+}
+
+// CheckText 检查文本键在tx中语言表的每种语言中都有文本，空键不检查
+func CheckText(tx *Tx, field, key string) error {
+	t := texts.In(tx)
+	if key == "" || t == nil {
+		return nil
+	}
+	r, ok := t.rows[key]
+	if !ok {
+		return fmt.Errorf("%s: text key %q not found in %s", field, key, langFile)
+	}
+	var missing []string
+	for i, v := range r.texts {
+		if strings.TrimSpace(v) == "" {
+			missing = append(missing, t.tags[i].String())
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%s: text key %q has no %s text", field, key, strings.Join(missing, ", "))
+	}
+	return nil
+}
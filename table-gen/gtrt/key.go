@@ -0,0 +1,19 @@
+package gtrt
+
+// Key2 两个int字段组成的主键
+type Key2 struct {
+	K1, K2 int
+}
+
+// Key3 三个int字段组成的主键
+type Key3 struct {
+	K1, K2, K3 int
+}
+
+func MakeKey2(k1, k2 int) Key2 {
+	return Key2{k1, k2}
+}
+
+func MakeKey3(k1, k2, k3 int) Key3 {
+	return Key3{k1, k2, k3}
+}
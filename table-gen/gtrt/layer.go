@@ -0,0 +1,123 @@
+package gtrt
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// 覆盖文件中_op列的取值，为空时有则替换、无则新增
+const (
+	LayerAdd     = "add"
+	LayerReplace = "replace"
+	LayerDelete  = "delete"
+)
+
+// layerOpColumn 覆盖文件中指定操作的列
+const layerOpColumn = "_op"
+
+var layers []string
+
+// SetLayers 设置覆盖层，如 SetLayers("cn", "cn/test")。
+// 每层是DataDir下的子目录，其中与基础表同名的csv按主键覆盖基础表，
+// 替换时只覆盖文件中有的列，后面的层覆盖前面的层。需在LoadAll之前调用，运行中修改后需重新加载全部表
+func SetLayers(names ...string) {
+	layers = append([]string(nil), names...)
+}
+
+// Layers 返回当前的覆盖层
+func Layers() []string {
+	return layers
+}
+
+// LayerOp 覆盖文件对一行数据的操作
+type LayerOp struct {
+	Layer string
+	File  string
+	Line  int
+	Op    string // add、replace或delete
+	Key   string
+}
+
+func (o LayerOp) String() string {
+	return fmt.Sprintf("%s:%d: %s %s", o.File, o.Line, o.Op, o.Key)
+}
+
+// readLayers 按层的顺序读取各层中存在的覆盖文件
+func readLayers(files []string) ([]*Table, error) {
+	ts := make([]*Table, 0)
+	for _, layer := range layers {
+		for _, v := range files {
+			name := filepath.Join(layer, v)
+			if _, err := os.Stat(filepath.Join(dataDir, name)); os.IsNotExist(err) {
+				continue
+			}
+			t, err := ReadTable(name)
+			if err != nil {
+				return nil, err
+			}
+			t.Layer = layer
+			ts = append(ts, t)
+		}
+	}
+	return ts, nil
+}
+
+// applyLayer 把覆盖文件按_op列合并到m，同一文件中主键不能重复。
+// 删除只解析主键列；替换在原有行的副本上解析，覆盖文件中没有的列保留原值；
+// 新增要求覆盖文件有全部没有默认值的列
+func applyLayer[K comparable, T any](t *Table, m map[K]*T, c Columns[T], key func(*T) K) ([]LayerOp, error) {
+	opIdx := BindColumns(t.Header, layerOpColumn)[0]
+	seen := make(map[K]bool)
+	ops := make([]LayerOp, 0, len(t.Rows))
+	for i, row := range t.Rows {
+		kv, err := c.Key(row)
+		if err != nil {
+			return nil, t.RowError(i, err)
+		}
+		k := key(kv)
+		if seen[k] {
+			return nil, t.RowError(i, fmt.Errorf("duplicate key %v in override", k))
+		}
+		seen[k] = true
+
+		base, exists := m[k]
+		op := strings.ToLower(strings.TrimSpace(CellOr(row, opIdx, "")))
+		switch op {
+		case "":
+			op = LayerAdd
+			if exists {
+				op = LayerReplace
+			}
+		case LayerAdd:
+			if exists {
+				return nil, t.RowError(i, fmt.Errorf("add: key %v already exists", k))
+			}
+		case LayerReplace, LayerDelete:
+			if !exists {
+				return nil, t.RowError(i, fmt.Errorf("%s: key %v not found", op, k))
+			}
+		default:
+			return nil, t.RowError(i, fmt.Errorf("unknown %s %q", layerOpColumn, op))
+		}
+		switch op {
+		case LayerDelete:
+			delete(m, k)
+		case LayerAdd:
+			if len(c.Missing) > 0 {
+				return nil, t.RowError(i, fmt.Errorf("add: column %s not found in header", strings.Join(c.Missing, ", ")))
+			}
+			base = nil
+			fallthrough
+		default:
+			v, err := c.Parse(row, base)
+			if err != nil {
+				return nil, t.RowError(i, err)
+			}
+			m[k] = v
+		}
+		ops = append(ops, LayerOp{Layer: t.Layer, File: t.File, Line: t.Lines[i], Op: op, Key: fmt.Sprint(k)})
+	}
+	return ops, nil
+}
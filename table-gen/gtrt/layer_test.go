@@ -0,0 +1,150 @@
+package gtrt
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestApplyLayer(t *testing.T) {
+	base := func() map[int]*testRow {
+		return map[int]*testRow{1: {id: 1, name: "a"}, 2: {id: 2, name: "b"}}
+	}
+	tests := []struct {
+		name  string
+		layer *Table
+		want  map[int]string
+		ops   []string
+		err   string
+	}{
+		{
+			name:  "empty op replaces or adds",
+			layer: testTable("cn/a.csv", "Id,Name,_op", "1,x,", "3,y,"),
+			want:  map[int]string{1: "x", 2: "b", 3: "y"},
+			ops:   []string{"replace 1", "add 3"},
+		},
+		{
+			name:  "delete parses only the key",
+			layer: testTable("cn/a.csv", "Id,_op", "2,delete"),
+			want:  map[int]string{1: "a"},
+			ops:   []string{"delete 2"},
+		},
+		{
+			name:  "replace keeps missing columns",
+			layer: testTable("cn/a.csv", "Id,_op", "1,Replace"),
+			want:  map[int]string{1: "a", 2: "b"},
+			ops:   []string{"replace 1"},
+		},
+		{
+			name:  "add existing key",
+			layer: testTable("cn/a.csv", "Id,Name,_op", "1,x,add"),
+			err:   "cn/a.csv:2: add: key 1 already exists",
+		},
+		{
+			name:  "replace missing key",
+			layer: testTable("cn/a.csv", "Id,Name,_op", "9,x,replace"),
+			err:   "cn/a.csv:2: replace: key 9 not found",
+		},
+		{
+			name:  "delete missing key",
+			layer: testTable("cn/a.csv", "Id,_op", "9,delete"),
+			err:   "cn/a.csv:2: delete: key 9 not found",
+		},
+		{
+			name:  "duplicate key",
+			layer: testTable("cn/a.csv", "Id,Name", "1,x", "1,y"),
+			err:   "cn/a.csv:3: duplicate key 1 in override",
+		},
+		{
+			name:  "unknown op",
+			layer: testTable("cn/a.csv", "Id,Name,_op", "1,x,upd"),
+			err:   `cn/a.csv:2: unknown _op "upd"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := base()
+			tt.layer.Layer = "cn"
+			ops, err := applyLayer(tt.layer, m, bindTestRows(tt.layer.Header), testRowKey)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("err = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkRows(t, m, tt.want)
+			got := make([]string, 0, len(ops))
+			for _, v := range ops {
+				got = append(got, v.Op+" "+v.Key)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.ops, ",") {
+				t.Errorf("ops = %v, want %v", got, tt.ops)
+			}
+		})
+	}
+}
+
+func TestApplyLayerAddNeedsAllColumns(t *testing.T) {
+	layer := testTable("cn/a.csv", "Id,_op", "3,add")
+	layer.Layer = "cn"
+	c := bindTestRows(layer.Header)
+	c.Missing = MissingColumns(layer.Header, "Id", "Name")
+	_, err := applyLayer(layer, map[int]*testRow{}, c, testRowKey)
+	if err == nil || err.Error() != "cn/a.csv:2: add: column Name not found in header" {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestLoadTablesLayerMissingKey(t *testing.T) {
+	layer := testTable("cn/a.csv", "Name,_op", "x,delete")
+	layer.Layer = "cn"
+	ts := []*Table{testTable("a.csv", "Id,Name", "1,a"), layer}
+	_, err := LoadTables(ts, map[int]*testRow{}, bindTestRows, testRowKey)
+	if err == nil || err.Error() != "cn/a.csv: column Id not found in header" {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestReadTablesLayers(t *testing.T) {
+	dir := t.TempDir()
+	oldDir, oldLayers := dataDir, layers
+	SetDataDir(dir)
+	SetLayers("cn", "cn/test")
+	t.Cleanup(func() { dataDir, layers = oldDir, oldLayers })
+	files := map[string]string{
+		"a.csv":         "Id,Name\n1,a\n2,b\n",
+		"cn/a.csv":      "Id,Name\n1,x\n",
+		"cn/test/a.csv": "Id,_op\n2,delete\n",
+		"b.csv":         "Id,Name\n9,z\n",
+	}
+	for name, data := range files {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ts, err := ReadTables("a.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make([]string, 0, len(ts))
+	for _, v := range ts {
+		got = append(got, v.Layer+":"+filepath.ToSlash(v.File))
+	}
+	if want := ":a.csv,cn:cn/a.csv,cn/test:cn/test/a.csv"; strings.Join(got, ",") != want {
+		t.Fatalf("tables = %v, want %s", got, want)
+	}
+	m := make(map[int]*testRow)
+	if _, err := LoadTables(ts, m, bindTestRows, testRowKey); err != nil {
+		t.Fatal(err)
+	}
+	checkRows(t, m, map[int]string{1: "x"})
+}
@@ -0,0 +1,155 @@
+package gtrt
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// lint的检查项
+const (
+	lintLoad   = "load"
+	lintRef    = "ref"
+	lintUnique = "unique"
+	lintCheck  = "check"
+	lintOrphan = "orphan"
+)
+
+// LintIssue 检查发现的一个问题，Level为error或warning
+type LintIssue struct {
+	Level   string `json:"level"`
+	Check   string `json:"check"`
+	Table   string `json:"table,omitempty"`
+	Key     string `json:"key,omitempty"`
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+// LintReport table-gen lint 生成的测试代码填充的报告
+type LintReport struct {
+	Errors   int         `json:"errors"`
+	Warnings int         `json:"warnings"`
+	Issues   []LintIssue `json:"issues"`
+
+	refs map[string]map[any]bool // 被引用过的主键，用于孤立行检查
+}
+
+func (r *LintReport) add(level string, issue LintIssue) {
+	issue.Level = level
+	if level == "error" {
+		r.Errors++
+	} else {
+		r.Warnings++
+	}
+	r.Issues = append(r.Issues, issue)
+}
+
+// Load 加载全部表，失败时把每个失败拆成单独的问题，返回是否可以继续检查
+func (r *LintReport) Load(load func() error) bool {
+	err := load()
+	if err == nil {
+		return true
+	}
+	var re *ReloadError
+	if !errors.As(err, &re) {
+		r.add("error", LintIssue{Check: lintLoad, Message: err.Error()})
+		return false
+	}
+	for _, f := range re.Failures {
+		var ve *ValidationError
+		if errors.As(f.Err, &ve) {
+			for _, v := range ve.Errs {
+				r.add("error", LintIssue{Check: f.Stage, Table: f.Table, Message: v.Error()})
+			}
+			continue
+		}
+		r.add("error", LintIssue{Check: f.Stage, Table: f.Table, Message: f.Err.Error()})
+	}
+	return false
+}
+
+// LintRefs 检查table每行field引用的值都是target表的主键
+func LintRefs[K, V comparable, T, P any](r *LintReport, table, field string, rows map[K]*T, values func(*T) []V, target string, targets map[V]*P) {
+	if r.refs == nil {
+		r.refs = make(map[string]map[any]bool)
+	}
+	if r.refs[target] == nil {
+		r.refs[target] = make(map[any]bool)
+	}
+	for _, k := range sortedKeys(rows) {
+		for _, v := range values(rows[k]) {
+			if _, ok := targets[v]; !ok {
+				r.add("error", LintIssue{Check: lintRef, Table: table, Key: fmt.Sprint(k), Field: field,
+					Message: fmt.Sprintf("%v not found in %s", v, target)})
+				continue
+			}
+			r.refs[target][v] = true
+		}
+	}
+}
+
+// LintUnique 检查table的field在所有行中不重复
+func LintUnique[K, V comparable, T any](r *LintReport, table, field string, rows map[K]*T, value func(*T) V) {
+	first := make(map[V]K, len(rows))
+	for _, k := range sortedKeys(rows) {
+		v := value(rows[k])
+		if k0, ok := first[v]; ok {
+			r.add("error", LintIssue{Check: lintUnique, Table: table, Key: fmt.Sprint(k), Field: field,
+				Message: fmt.Sprintf("duplicate value %v, first used by key %v", v, k0)})
+			continue
+		}
+		first[v] = k
+	}
+}
+
+// LintFunc 执行 @check 标注的检查函数
+func LintFunc(r *LintReport, name string, errs ...error) {
+	for _, err := range errs {
+		if err != nil {
+			r.add("error", LintIssue{Check: lintCheck, Message: fmt.Sprintf("%s: %v", name, err)})
+		}
+	}
+}
+
+// LintOrphans 报告被引用的表中从未被引用的行，需在所有LintRefs之后调用
+func LintOrphans[K comparable, T any](r *LintReport, table string, rows map[K]*T) {
+	refs, ok := r.refs[table]
+	if !ok {
+		return
+	}
+	for _, k := range sortedKeys(rows) {
+		if !refs[k] {
+			r.add("warning", LintIssue{Check: lintOrphan, Table: table, Key: fmt.Sprint(k),
+				Message: "row is not referenced by any table"})
+		}
+	}
+}
+
+// WriteFile 以json写出报告
+func (r *LintReport) WriteFile(file string) error {
+	if r.Issues == nil {
+		r.Issues = []LintIssue{}
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(r); err != nil {
+		return err
+	}
+	return os.WriteFile(file, buf.Bytes(), 0o644)
+}
+
+func sortedKeys[K comparable, T any](m map[K]*T) []K {
+	keys := make([]K, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return compareKey(keys[i], keys[j]) < 0
+	})
+	return keys
+}
@@ -0,0 +1,155 @@
+package gtrt
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unsafe"
+)
+
+// 单元格解析函数，供生成的parseXRow使用，空单元格为零值
+
+func ParseInt[T ~int | ~int8 | ~int16 | ~int32 | ~int64](s string) (T, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+	var zero T
+	n, err := strconv.ParseInt(s, 10, int(unsafe.Sizeof(zero))*8)
+	return T(n), err
+}
+
+func ParseUint[T ~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64](s string) (T, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+	var zero T
+	n, err := strconv.ParseUint(s, 10, int(unsafe.Sizeof(zero))*8)
+	return T(n), err
+}
+
+func ParseFloat[T ~float32 | ~float64](s string) (T, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+	var zero T
+	n, err := strconv.ParseFloat(s, int(unsafe.Sizeof(zero))*8)
+	return T(n), err
+}
+
+func ParseBool[T ~bool](s string) (T, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(s)
+	return T(b), err
+}
+
+func ParseString[T ~string](s string) (T, error) {
+	return T(strings.TrimSpace(s)), nil
+}
+
+// timeLocation 解析不带时区的时间所用的时区
+var timeLocation = time.Local
+
+// SetTimeLocation 设置解析不带时区的时间列所用的时区，默认为本地时区，需在LoadAll之前调用
+func SetTimeLocation(loc *time.Location) {
+	timeLocation = loc
+}
+
+var timeLayouts = []string{time.DateTime, "2006-01-02 15:04", time.DateOnly, "2006/01/02 15:04:05", "2006/01/02 15:04", "2006/01/02", time.RFC3339}
+
+// ParseTime 解析时间列，如 2025-01-02 15:04:05、2025-01-02 或以秒为单位的时间戳
+func ParseTime(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return UnixTime(n), nil
+	}
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, s, timeLocation); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q", s)
+}
+
+// ParseSlice 按sep切分单元格，逐个元素用parse解析，如 1|2|3
+func ParseSlice[S ~[]T, T any](s, sep string, parse func(string) (T, error)) (S, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+	parts := strings.Split(s, sep)
+	lst := make(S, 0, len(parts))
+	for i, v := range parts {
+		e, err := parse(v)
+		if err != nil {
+			return nil, fmt.Errorf("element %d %q: %w", i+1, v, err)
+		}
+		lst = append(lst, e)
+	}
+	return lst, nil
+}
+
+// ParseMap 按sep切分键值对，再按kv切分键和值，如 1:10;2:20
+func ParseMap[M ~map[K]V, K comparable, V any](s, sep, kv string, parseKey func(string) (K, error), parseValue func(string) (V, error)) (M, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+	parts := strings.Split(s, sep)
+	m := make(M, len(parts))
+	for i, v := range parts {
+		ks, vs, ok := strings.Cut(v, kv)
+		if !ok {
+			return nil, fmt.Errorf("pair %d %q: missing %q", i+1, v, kv)
+		}
+		k, err := parseKey(ks)
+		if err != nil {
+			return nil, fmt.Errorf("pair %d key %q: %w", i+1, ks, err)
+		}
+		if _, ok := m[k]; ok {
+			return nil, fmt.Errorf("pair %d: duplicate key %q", i+1, ks)
+		}
+		if m[k], err = parseValue(vs); err != nil {
+			return nil, fmt.Errorf("pair %d value %q: %w", i+1, vs, err)
+		}
+	}
+	return m, nil
+}
+
+// ParseJSON 将单元格按json解析，用于嵌套结构
+func ParseJSON[T any](s string) (T, error) {
+	var v T
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return v, nil
+	}
+	err := json.Unmarshal([]byte(s), &v)
+	return v, err
+}
+
+type integer interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 | ~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64
+}
+
+// ParseEnum 按生成的名字表解析枚举，名字不区分大小写，也接受已声明的数值，未知的名字报错
+func ParseEnum[T, E integer](s, name string, values map[string]E) (T, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+	v, ok := values[strings.ToLower(s)]
+	if !ok {
+		return 0, fmt.Errorf("unknown %s %q", name, s)
+	}
+	return T(v), nil
+}
@@ -0,0 +1,230 @@
+package gtrt
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// TableInfo 生成代码登记的表信息
+type TableInfo struct {
+	Name   string
+	Files  []string        // @excel 和 @csv 文件
+	Depend []string        // @depend 依赖的表
+	Load   func(*Tx) error // 解析并构建，新数据暂存到tx，发布操作通过 tx.AppendAfterLoad 登记
+	Check  func(*Tx) error // 发布前对tx中暂存数据的校验，可为nil
+}
+
+const (
+	StageLoad  = "load"
+	StageCheck = "check"
+)
+
+// Failure 单个表的加载或校验失败
+type Failure struct {
+	Table string
+	Stage string
+	Err   error
+}
+
+// ReloadError 加载失败的全部原因，此时数据保持加载前的状态
+type ReloadError struct {
+	Failures []Failure
+}
+
+func (e *ReloadError) Error() string {
+	lst := make([]string, 0, len(e.Failures))
+	for _, v := range e.Failures {
+		lst = append(lst, fmt.Sprintf("%s %s: %v", v.Table, v.Stage, v.Err))
+	}
+	return fmt.Sprintf("reload failed (%d):\n\t%s", len(e.Failures), strings.Join(lst, "\n\t"))
+}
+
+func (e *ReloadError) Unwrap() []error {
+	lst := make([]error, 0, len(e.Failures))
+	for _, v := range e.Failures {
+		lst = append(lst, v.Err)
+	}
+	return lst
+}
+
+func (e *ReloadError) add(table, stage string, err error) {
+	e.Failures = append(e.Failures, Failure{Table: table, Stage: stage, Err: err})
+}
+
+var (
+	tablesMu sync.Mutex
+	tables   = make(map[string]*TableInfo)
+)
+
+// RegisterTable 登记表，生成代码在init中调用
+func RegisterTable(t TableInfo) {
+	tablesMu.Lock()
+	defer tablesMu.Unlock()
+	tables[t.Name] = &t
+}
+
+// LoadAll 加载全部登记的表
+func LoadAll() error {
+	tablesMu.Lock()
+	names := make([]string, 0, len(tables))
+	for k := range tables {
+		names = append(names, k)
+	}
+	tablesMu.Unlock()
+	return Reload(names...)
+}
+
+// HotLoad 重新加载文件对应的表
+func HotLoad(files ...string) error {
+	tablesMu.Lock()
+	names := make([]string, 0, len(files))
+	for _, t := range tables {
+		for _, f := range files {
+			if slices.ContainsFunc(t.Files, func(p string) bool { return matchFile(p, f) }) {
+				names = append(names, t.Name)
+				break
+			}
+		}
+	}
+	tablesMu.Unlock()
+	if len(names) == 0 {
+		return nil
+	}
+	return Reload(names...)
+}
+
+// Reload 重新加载指定的表及依赖它们的表：
+// 按依赖顺序构建并暂存到同一个Tx，依赖表和校验通过tx读到本次暂存的数据，全部通过后才统一发布，
+// 任一失败则丢弃暂存的数据，其他goroutine始终读不到失败的数据。
+// OnCommit回调和变更通知只在发布后执行，快照不会出现失败的数据
+func Reload(names ...string) error {
+	loadMu.Lock()
+	defer loadMu.Unlock()
+
+	order, err := affected(names)
+	if err != nil {
+		return err
+	}
+
+	tx := newTx()
+	re := &ReloadError{}
+	for _, t := range order {
+		if err := safeCall(tx, t.Load); err != nil {
+			re.add(t.Name, StageLoad, err)
+		}
+	}
+	if len(re.Failures) > 0 {
+		return re
+	}
+	for _, t := range order {
+		if t.Check == nil {
+			continue
+		}
+		if err := safeCall(tx, t.Check); err != nil {
+			re.add(t.Name, StageCheck, err)
+		}
+	}
+	if len(re.Failures) > 0 {
+		return re
+	}
+
+	for _, fn := range tx.publish {
+		fn()
+	}
+	for _, fn := range commitHooks() {
+		fn()
+	}
+	for _, fn := range tx.after {
+		fn()
+	}
+	return nil
+}
+
+// affected 返回需要重新加载的表，包含所有直接或间接依赖它们的表，被依赖的表排在前面
+func affected(names []string) ([]*TableInfo, error) {
+	tablesMu.Lock()
+	defer tablesMu.Unlock()
+
+	set := make(map[string]bool)
+	var mark func(name string)
+	mark = func(name string) {
+		if set[name] {
+			return
+		}
+		set[name] = true
+		for _, t := range tables {
+			if slices.Contains(t.Depend, name) {
+				mark(t.Name)
+			}
+		}
+	}
+	for _, v := range names {
+		name, ok := lookupTable(v)
+		if !ok {
+			return nil, fmt.Errorf("unknown table %s", v)
+		}
+		mark(name)
+	}
+
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	order := make([]*TableInfo, 0, len(keys))
+	state := make(map[string]int) // 1访问中 2已完成
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch state[name] {
+		case 1:
+			return fmt.Errorf("circular @depend at table %s", name)
+		case 2:
+			return nil
+		}
+		state[name] = 1
+		t := tables[name]
+		for _, v := range t.Depend {
+			if set[v] {
+				if err := visit(v); err != nil {
+					return err
+				}
+			}
+		}
+		state[name] = 2
+		order = append(order, t)
+		return nil
+	}
+	for _, k := range keys {
+		if err := visit(k); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+// lookupTable 按表名查找，找不到时忽略大小写匹配，便于GM命令输入
+func lookupTable(name string) (string, bool) {
+	if _, ok := tables[name]; ok {
+		return name, true
+	}
+	for k := range tables {
+		if strings.EqualFold(k, name) {
+			return k, true
+		}
+	}
+	return "", false
+}
+
+// safeCall 执行加载或校验，afterLoad等手写代码中的panic转为错误
+func safeCall(tx *Tx, fn func(*Tx) error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return fn(tx)
+}
@@ -0,0 +1,176 @@
+package gtrt
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// reloadFixture 两张测试表，Derived依赖Base，加载时通过tx读取Base本次暂存的数据
+type reloadFixture struct {
+	base, derived Var[int]
+	next          int   // Base下次加载的值
+	loadErr       error // Derived加载返回的错误
+	panicLoad     bool
+	checkErr      error
+	seen          []int // Derived加载和校验时读到的Base
+	other         int   // 校验时其他goroutine读到的已发布的Base
+	otherTx       int   // 校验时其他goroutine通过tx读到的Base
+	notified      int
+}
+
+func intOr(p *int, v int) int {
+	if p == nil {
+		return v
+	}
+	return *p
+}
+
+func newReloadFixture(t *testing.T) *reloadFixture {
+	f := &reloadFixture{}
+	RegisterTable(TableInfo{Name: "TestBase", Load: func(tx *Tx) error {
+		v := f.next
+		f.base.Stage(tx, &v)
+		tx.AppendAfterLoad(func() {
+			f.base.Store(&v)
+			tx.AfterCommit(func() { f.notified++ })
+		})
+		return nil
+	}})
+	RegisterTable(TableInfo{Name: "TestDerived", Depend: []string{"TestBase"}, Load: func(tx *Tx) error {
+		if f.panicLoad {
+			panic("boom")
+		}
+		if f.loadErr != nil {
+			return f.loadErr
+		}
+		v := *f.base.In(tx) * 10
+		f.seen = append(f.seen, *f.base.In(tx))
+		f.derived.Stage(tx, &v)
+		tx.AppendAfterLoad(func() {
+			f.derived.Store(&v)
+		})
+		return nil
+	}, Check: func(tx *Tx) error {
+		f.seen = append(f.seen, *f.derived.In(tx))
+		done := make(chan [2]int)
+		go func() {
+			done <- [2]int{intOr(f.base.Load(), -1), *f.base.In(tx)}
+		}()
+		v := <-done
+		f.other, f.otherTx = v[0], v[1]
+		return f.checkErr
+	}})
+	t.Cleanup(func() {
+		tablesMu.Lock()
+		delete(tables, "TestBase")
+		delete(tables, "TestDerived")
+		tablesMu.Unlock()
+	})
+	return f
+}
+
+func TestReload(t *testing.T) {
+	tests := []struct {
+		name      string
+		next      int
+		loadErr   error
+		panicLoad bool
+		checkErr  error
+		err       string
+		live      [2]int // 加载后的Base和Derived
+		seen      []int
+	}{
+		{name: "publish", next: 2, live: [2]int{2, 20}, seen: []int{2, 20}},
+		{name: "check fails", next: 2, checkErr: errors.New("bad"), err: "TestDerived check: bad", live: [2]int{1, 10}, seen: []int{2, 20}},
+		{name: "load fails", next: 2, loadErr: errors.New("bad"), err: "TestDerived load: bad", live: [2]int{1, 10}},
+		{name: "load panics", next: 2, panicLoad: true, err: "TestDerived load: panic: boom", live: [2]int{1, 10}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newReloadFixture(t)
+			f.next = 1
+			if err := Reload("TestBase"); err != nil {
+				t.Fatal(err)
+			}
+			f.seen, f.notified = nil, 0
+
+			f.next, f.loadErr, f.panicLoad, f.checkErr = tt.next, tt.loadErr, tt.panicLoad, tt.checkErr
+			err := Reload("testbase")
+			if tt.err == "" && err != nil || tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Fatalf("err = %v, want %q", err, tt.err)
+			}
+			var re *ReloadError
+			if tt.err != "" && !errors.As(err, &re) {
+				t.Errorf("err is %T, want *ReloadError", err)
+			}
+			if got := [2]int{*f.base.Load(), *f.derived.Load()}; got != tt.live {
+				t.Errorf("live = %v, want %v", got, tt.live)
+			}
+			if !slices.Equal(f.seen, tt.seen) {
+				t.Errorf("seen = %v, want %v", f.seen, tt.seen)
+			}
+			if tt.seen != nil && (f.other != 1 || f.otherTx != tt.next) {
+				t.Errorf("other goroutine saw %d and %d through tx during check, want 1 and %d", f.other, f.otherTx, tt.next)
+			}
+			want := 0
+			if tt.err == "" {
+				want = 1
+			}
+			if f.notified != want {
+				t.Errorf("notified %d times, want %d", f.notified, want)
+			}
+		})
+	}
+}
+
+func TestVarIn(t *testing.T) {
+	var v, other Var[int]
+	a, b, c := 1, 2, 3
+	v.Store(&a)
+	other.Store(&c)
+	tx := newTx()
+	v.Stage(tx, &b)
+	if *v.Load() != 1 || *v.In(nil) != 1 {
+		t.Errorf("Load = %d, In(nil) = %d, want 1", *v.Load(), *v.In(nil))
+	}
+	if *v.In(tx) != 2 {
+		t.Errorf("In(tx) = %d, want 2", *v.In(tx))
+	}
+	if *other.In(tx) != 3 {
+		t.Errorf("unstaged In(tx) = %d, want 3", *other.In(tx))
+	}
+	if *v.In(newTx()) != 1 {
+		t.Errorf("In(another tx) = %d, want 1", *v.In(newTx()))
+	}
+}
+
+func TestAffected(t *testing.T) {
+	newReloadFixture(t)
+	tests := []struct {
+		names []string
+		want  string
+		err   string
+	}{
+		{names: []string{"TestBase"}, want: "TestBase,TestDerived"},
+		{names: []string{"TestDerived"}, want: "TestDerived"},
+		{names: []string{"Nope"}, err: "unknown table Nope"},
+	}
+	for _, tt := range tests {
+		order, err := affected(tt.names)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("affected(%v) err = %v, want %q", tt.names, err, tt.err)
+			}
+			continue
+		}
+		lst := make([]string, 0, len(order))
+		for _, v := range order {
+			lst = append(lst, v.Name)
+		}
+		if got := strings.Join(lst, ","); got != tt.want {
+			t.Errorf("affected(%v) = %s, want %s", tt.names, got, tt.want)
+		}
+	}
+}
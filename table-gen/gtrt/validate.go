@@ -0,0 +1,40 @@
+package gtrt
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ValidationError 一张表所有违反字段约束的行，按主键排序
+type ValidationError struct {
+	Table string
+	Errs  []error
+}
+
+func (e *ValidationError) Error() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s: %d violation(s)", e.Table, len(e.Errs))
+	for _, v := range e.Errs {
+		sb.WriteString("\n\t")
+		sb.WriteString(v.Error())
+	}
+	return sb.String()
+}
+
+func (e *ValidationError) Unwrap() []error {
+	return e.Errs
+}
+
+// Validate 对每行执行生成的validate，收集全部违规而不是遇到第一个就返回
+func Validate[K comparable, T any](table string, m map[K]*T, validate func(*T) []error) error {
+	var errs []error
+	for _, k := range sortedKeys(m) {
+		for _, err := range validate(m[k]) {
+			errs = append(errs, fmt.Errorf("key %v: %w", k, err))
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return &ValidationError{Table: table, Errs: errs}
+}
@@ -0,0 +1,61 @@
+package gtrt
+
+import "sync/atomic"
+
+// Var 生成代码中表数据和自定义集合的发布点。
+// Reload期间新数据暂存在本次的Tx中，依赖表和校验通过 In(tx) 读到本次加载的数据，
+// 全部通过后才发布，发布前 Load 读到的始终是旧数据
+type Var[T any] struct {
+	live atomic.Pointer[T]
+}
+
+// Tx 一次Reload的加载事务，由Reload创建并传给每个表的加载和校验函数
+type Tx struct {
+	staged  map[any]any // *Var[T] -> *T
+	publish []func()
+	after   []func()
+}
+
+func newTx() *Tx {
+	return &Tx{staged: make(map[any]any)}
+}
+
+// Load 返回已发布的数据
+func (v *Var[T]) Load() *T {
+	return v.live.Load()
+}
+
+// In 返回tx中暂存的数据，本次没有加载时返回已发布的数据，tx为nil时同 Load
+func (v *Var[T]) In(tx *Tx) *T {
+	if tx != nil {
+		if p, ok := tx.staged[v]; ok {
+			return p.(*T)
+		}
+	}
+	return v.live.Load()
+}
+
+// Stage 把本次加载构建的数据暂存到tx，发布操作仍需通过 tx.AppendAfterLoad 登记
+func (v *Var[T]) Stage(tx *Tx, p *T) {
+	tx.staged[v] = p
+}
+
+// Swap 发布新数据并返回旧数据
+func (v *Var[T]) Swap(p *T) *T {
+	return v.live.Swap(p)
+}
+
+// Store 发布新数据
+func (v *Var[T]) Store(p *T) {
+	v.live.Store(p)
+}
+
+// AppendAfterLoad 登记表的发布操作，本次加载的全部表构建并校验通过后才统一执行
+func (tx *Tx) AppendAfterLoad(publish func()) {
+	tx.publish = append(tx.publish, publish)
+}
+
+// AfterCommit 在发布操作中登记本次加载成功后的通知
+func (tx *Tx) AfterCommit(fn func()) {
+	tx.after = append(tx.after, fn)
+}
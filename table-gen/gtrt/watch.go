@@ -0,0 +1,119 @@
+package gtrt
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"time"
+)
+
+// Watcher 轮询已登记表的数据文件，文件停止变化Debounce时长后触发热加载。
+// 使用轮询而非系统通知，任何平台和挂载目录下都可用
+type Watcher struct {
+	Interval time.Duration                   // 轮询间隔
+	Debounce time.Duration                   // 最后一次变化后等待的时长
+	OnReload func(files []string, err error) // 每次热加载后的回调，用于记录日志
+
+	stats   map[string]fileStat
+	changed map[string]time.Time
+}
+
+type fileStat struct {
+	mod  time.Time
+	size int64
+}
+
+func NewWatcher() *Watcher {
+	return &Watcher{
+		Interval: time.Second,
+		Debounce: 500 * time.Millisecond,
+	}
+}
+
+// Run 阻塞运行直到ctx结束，需在LoadAll之后调用
+func (w *Watcher) Run(ctx context.Context) {
+	w.stats = w.scan()
+	w.changed = make(map[string]time.Time)
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			w.poll(now)
+		}
+	}
+}
+
+func (w *Watcher) poll(now time.Time) {
+	cur := w.scan()
+	for file, st := range cur {
+		if old, ok := w.stats[file]; !ok || old != st {
+			w.changed[file] = now
+		}
+	}
+	// 删除的文件也算变化，如去掉覆盖层文件或glob匹配的文件，需重新加载所属的表
+	for file := range w.stats {
+		if _, ok := cur[file]; !ok {
+			w.changed[file] = now
+		}
+	}
+	w.stats = cur
+
+	ready := make([]string, 0)
+	for file, t := range w.changed {
+		if now.Sub(t) >= w.Debounce {
+			ready = append(ready, file)
+			delete(w.changed, file)
+		}
+	}
+	if len(ready) == 0 {
+		return
+	}
+	sort.Strings(ready)
+	err := HotLoad(ready...)
+	if w.OnReload != nil {
+		w.OnReload(ready, err)
+	}
+}
+
+// scan 获取已登记数据文件的修改时间和大小，不存在的文件忽略
+func (w *Watcher) scan() map[string]fileStat {
+	tablesMu.Lock()
+	files := make([]string, 0, len(tables)*2)
+	for _, t := range tables {
+		files = append(files, t.Files...)
+	}
+	tablesMu.Unlock()
+
+	// glob展开失败或没有匹配时忽略，只监视已存在的文件
+	for _, v := range slices.Clone(files) {
+		if hasMeta(v) {
+			matches, _ := expandFiles([]string{v})
+			files = append(files, matches...)
+		}
+	}
+	// 各覆盖层中的同名文件
+	for _, v := range slices.Clone(files) {
+		for _, layer := range layers {
+			if !hasMeta(v) {
+				files = append(files, filepath.Join(layer, v))
+			}
+		}
+	}
+	stats := make(map[string]fileStat, len(files))
+	for _, v := range files {
+		if hasMeta(v) {
+			continue
+		}
+		fi, err := os.Stat(filepath.Join(dataDir, v))
+		if err != nil {
+			continue
+		}
+		stats[v] = fileStat{mod: fi.ModTime(), size: fi.Size()}
+	}
+	return stats
+}
@@ -0,0 +1,95 @@
+package gtrt
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestWatcherPoll(t *testing.T) {
+	dir := t.TempDir()
+	oldDir, oldLayers := dataDir, layers
+	SetDataDir(dir)
+	SetLayers("cn")
+	t.Cleanup(func() {
+		dataDir, layers = oldDir, oldLayers
+	})
+	write := func(name, data string) {
+		t.Helper()
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("a.csv", "Id\n1\n")
+	write(filepath.Join("cn", "a.csv"), "Id\n2\n")
+	write("m_1.csv", "Id\n1\n")
+	write("m_2.csv", "Id\n2\n")
+
+	loads := make(map[string]int)
+	for name, files := range map[string][]string{"TestWatchA": {"a.csv"}, "TestWatchM": {"m_*.csv"}} {
+		RegisterTable(TableInfo{Name: name, Files: files, Load: func(*Tx) error {
+			loads[name]++
+			return nil
+		}})
+	}
+	t.Cleanup(func() {
+		tablesMu.Lock()
+		delete(tables, "TestWatchA")
+		delete(tables, "TestWatchM")
+		tablesMu.Unlock()
+	})
+
+	w := NewWatcher()
+	var reloaded []string
+	w.OnReload = func(files []string, err error) {
+		if err != nil {
+			t.Errorf("reload %v: %v", files, err)
+		}
+		reloaded = append(reloaded, files...)
+	}
+	w.stats = w.scan()
+	w.changed = make(map[string]time.Time)
+
+	tests := []struct {
+		name   string
+		change func()
+		files  []string
+		loads  map[string]int
+	}{
+		{"nothing changed", func() {}, nil, map[string]int{}},
+		{"layer file removed", func() { os.Remove(filepath.Join(dir, "cn", "a.csv")) }, []string{filepath.Join("cn", "a.csv")}, map[string]int{"TestWatchA": 1}},
+		{"glob match removed", func() { os.Remove(filepath.Join(dir, "m_2.csv")) }, []string{"m_2.csv"}, map[string]int{"TestWatchM": 1}},
+		{"file added", func() { write("m_3.csv", "Id\n3\n") }, []string{"m_3.csv"}, map[string]int{"TestWatchM": 1}},
+	}
+	now := time.Now()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clear(loads)
+			reloaded = nil
+			tt.change()
+			w.poll(now)
+			if len(reloaded) != 0 {
+				t.Errorf("reloaded %v before the debounce", reloaded)
+			}
+			now = now.Add(w.Debounce)
+			w.poll(now)
+			if !slices.Equal(reloaded, tt.files) {
+				t.Errorf("reloaded %v, want %v", reloaded, tt.files)
+			}
+			for k, v := range tt.loads {
+				if loads[k] != v {
+					t.Errorf("%s loaded %d times, want %d", k, loads[k], v)
+				}
+			}
+			if len(loads) != len(tt.loads) {
+				t.Errorf("loads = %v, want %v", loads, tt.loads)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package gtrt
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"sort"
+)
+
+// Rand 随机数源，math/rand/v2 的 *rand.Rand 满足该接口，测试时可注入固定的序列
+type Rand interface {
+	IntN(n int) int
+}
+
+// globalRand 使用math/rand/v2的全局随机源
+type globalRand struct{}
+
+func (globalRand) IntN(n int) int {
+	return rand.IntN(n)
+}
+
+// Weighted 按权重随机的行，加载时预先计算权重的前缀和，随机时二分查找
+type Weighted[T any] struct {
+	rows []*T // 按主键排序，不含权重为0的行
+	sums []int
+}
+
+// NewWeighted 为m建立按权重随机的结构，权重为负的行报错，为0的行不会被选中
+func NewWeighted[K comparable, T any](table string, m map[K]*T, weight func(*T) int) (Weighted[T], error) {
+	var w Weighted[T]
+	var errs []error
+	for _, k := range sortedKeys(m) {
+		errs = w.add(errs, k, m[k], weight(m[k]))
+	}
+	if len(errs) > 0 {
+		return Weighted[T]{}, &ValidationError{Table: table, Errs: errs}
+	}
+	return w, nil
+}
+
+// NewWeightedGroups 按group分组后为每组建立按权重随机的结构
+func NewWeightedGroups[K, G comparable, T any](table string, m map[K]*T, group func(*T) G, weight func(*T) int) (map[G]Weighted[T], error) {
+	groups := make(map[G]Weighted[T])
+	var errs []error
+	for _, k := range sortedKeys(m) {
+		g := group(m[k])
+		w := groups[g]
+		errs = w.add(errs, k, m[k], weight(m[k]))
+		groups[g] = w
+	}
+	if len(errs) > 0 {
+		return nil, &ValidationError{Table: table, Errs: errs}
+	}
+	return groups, nil
+}
+
+func (p *Weighted[T]) add(errs []error, key any, row *T, weight int) []error {
+	total := p.Total()
+	switch {
+	case weight < 0:
+		return append(errs, fmt.Errorf("key %v: negative weight %d", key, weight))
+	case weight == 0:
+		return errs
+	case total+weight < total:
+		return append(errs, fmt.Errorf("key %v: total weight overflows", key))
+	}
+	p.rows = append(p.rows, row)
+	p.sums = append(p.sums, total+weight)
+	return errs
+}
+
+// Total 返回权重之和
+func (p *Weighted[T]) Total() int {
+	if len(p.sums) == 0 {
+		return 0
+	}
+	return p.sums[len(p.sums)-1]
+}
+
+// Pick 按权重随机一行，rng为nil时使用全局随机源，没有可选的行时返回nil
+func (p *Weighted[T]) Pick(rng Rand) *T {
+	total := p.Total()
+	if total == 0 {
+		return nil
+	}
+	if rng == nil {
+		rng = globalRand{}
+	}
+	r := rng.IntN(total)
+	i := sort.Search(len(p.sums), func(i int) bool {
+		return p.sums[i] > r
+	})
+	return p.rows[i]
+}
@@ -0,0 +1,104 @@
+package gtrt
+
+import "testing"
+
+// fixedRand 依次返回给定的值
+type fixedRand struct {
+	values []int
+	n      []int // 记录每次调用的参数
+}
+
+func (r *fixedRand) IntN(n int) int {
+	r.n = append(r.n, n)
+	v := r.values[0]
+	r.values = r.values[1:]
+	return v
+}
+
+type weightRow struct {
+	id, group, weight int
+}
+
+func rowWeight(p *weightRow) int {
+	return p.weight
+}
+
+func TestWeightedPick(t *testing.T) {
+	m := map[int]*weightRow{
+		1: {id: 1, weight: 10},
+		2: {id: 2, weight: 0},
+		3: {id: 3, weight: 30},
+		4: {id: 4, weight: 60},
+	}
+	w, err := NewWeighted("Test", m, rowWeight)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.Total() != 100 {
+		t.Fatalf("Total = %d, want 100", w.Total())
+	}
+	tests := []struct {
+		r    int
+		want int
+	}{
+		{0, 1},
+		{9, 1},
+		{10, 3},
+		{39, 3},
+		{40, 4},
+		{99, 4},
+	}
+	for _, tt := range tests {
+		rng := &fixedRand{values: []int{tt.r}}
+		if got := w.Pick(rng); got.id != tt.want {
+			t.Errorf("Pick with %d = %d, want %d", tt.r, got.id, tt.want)
+		}
+		if rng.n[0] != 100 {
+			t.Errorf("IntN called with %d, want 100", rng.n[0])
+		}
+	}
+}
+
+func TestWeightedEmptyAndErrors(t *testing.T) {
+	var empty Weighted[weightRow]
+	if got := empty.Pick(&fixedRand{}); got != nil {
+		t.Errorf("empty Pick = %v, want nil", got)
+	}
+
+	tests := []struct {
+		name string
+		m    map[int]*weightRow
+	}{
+		{"negative", map[int]*weightRow{1: {id: 1, weight: -1}}},
+		{"overflow", map[int]*weightRow{1: {id: 1, weight: int(^uint(0) >> 1)}, 2: {id: 2, weight: 1}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewWeighted("Test", tt.m, rowWeight); err == nil {
+				t.Error("want error")
+			}
+		})
+	}
+}
+
+func TestWeightedGroups(t *testing.T) {
+	m := map[int]*weightRow{
+		1: {id: 1, group: 1, weight: 5},
+		2: {id: 2, group: 1, weight: 5},
+		3: {id: 3, group: 2, weight: 7},
+	}
+	groups, err := NewWeightedGroups("Test", m, func(p *weightRow) int { return p.group }, rowWeight)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g1, g2 := groups[1], groups[2]
+	if g1.Total() != 10 || g2.Total() != 7 {
+		t.Fatalf("totals = %d, %d, want 10, 7", g1.Total(), g2.Total())
+	}
+	if got := g1.Pick(&fixedRand{values: []int{5}}); got.id != 2 {
+		t.Errorf("group 1 Pick = %d, want 2", got.id)
+	}
+	if got := g2.Pick(&fixedRand{values: []int{6}}); got.id != 3 {
+		t.Errorf("group 2 Pick = %d, want 3", got.id)
+	}
+}
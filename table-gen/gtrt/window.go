@@ -0,0 +1,103 @@
+package gtrt
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Window 行的有效时间段 [Start, End)，零值表示不限
+type Window struct {
+	Start time.Time
+	End   time.Time
+}
+
+// Contains 判断t是否在时间段内
+func (w Window) Contains(t time.Time) bool {
+	return (w.Start.IsZero() || !t.Before(w.Start)) && (w.End.IsZero() || t.Before(w.End))
+}
+
+// UnixTime 把以秒为单位的时间戳列转换为时间，0表示不限
+func UnixTime(sec int64) time.Time {
+	if sec == 0 {
+		return time.Time{}
+	}
+	return time.Unix(sec, 0)
+}
+
+// WindowIndex 按有效时间段索引的行。所有起止时间把时间轴切成若干段，
+// 每段预先算好有效的行，查询时二分查找所在的段
+type WindowIndex[T any] struct {
+	bounds []time.Time // 去重排序后的起止时间
+	active [][]*T      // active[i] 为 [bounds[i-1], bounds[i]) 内有效的行，按主键排序
+}
+
+// NewWindowIndex 为m建立时间段索引，结束时间不晚于开始时间的行报错
+func NewWindowIndex[K comparable, T any](table string, m map[K]*T, window func(*T) Window) (WindowIndex[T], error) {
+	keys := sortedKeys(m)
+	windows := make([]Window, len(keys))
+	var errs []error
+	var bounds []time.Time
+	for i, k := range keys {
+		w := window(m[k])
+		if !w.Start.IsZero() && !w.End.IsZero() && !w.End.After(w.Start) {
+			errs = append(errs, fmt.Errorf("key %v: end %s is not after start %s", k, w.End.Format(time.DateTime), w.Start.Format(time.DateTime)))
+		}
+		for _, t := range []time.Time{w.Start, w.End} {
+			if !t.IsZero() {
+				bounds = append(bounds, t)
+			}
+		}
+		windows[i] = w
+	}
+	if len(errs) > 0 {
+		return WindowIndex[T]{}, &ValidationError{Table: table, Errs: errs}
+	}
+	sort.Slice(bounds, func(i, j int) bool {
+		return bounds[i].Before(bounds[j])
+	})
+	n := 0
+	for i, t := range bounds {
+		if i == 0 || !t.Equal(bounds[n-1]) {
+			bounds[n] = t
+			n++
+		}
+	}
+	idx := WindowIndex[T]{bounds: bounds[:n], active: make([][]*T, n+1)}
+	for i, k := range keys {
+		from, to := 0, n+1
+		if w := windows[i]; !w.Start.IsZero() {
+			from = idx.segment(w.Start)
+		}
+		if w := windows[i]; !w.End.IsZero() {
+			to = idx.segment(w.End)
+		}
+		for s := from; s < to; s++ {
+			idx.active[s] = append(idx.active[s], m[k])
+		}
+	}
+	return idx, nil
+}
+
+// segment 返回t所在的段
+func (p *WindowIndex[T]) segment(t time.Time) int {
+	return sort.Search(len(p.bounds), func(i int) bool {
+		return p.bounds[i].After(t)
+	})
+}
+
+// Active 返回now时有效的行，结果共享不可修改
+func (p *WindowIndex[T]) Active(now time.Time) []*T {
+	if len(p.active) == 0 {
+		return nil
+	}
+	return p.active[p.segment(now)]
+}
+
+// Next 返回now之后最近一次有行开始或结束的时间，之后不再变化时返回零值
+func (p *WindowIndex[T]) Next(now time.Time) time.Time {
+	if i := p.segment(now); i < len(p.bounds) {
+		return p.bounds[i]
+	}
+	return time.Time{}
+}
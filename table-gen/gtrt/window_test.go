@@ -0,0 +1,101 @@
+package gtrt
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+type windowRow struct {
+	id         int
+	start, end time.Time
+}
+
+func day(d int) time.Time {
+	return time.Date(2026, 1, d, 0, 0, 0, 0, time.UTC)
+}
+
+func windowRows() map[int]*windowRow {
+	return map[int]*windowRow{
+		1: {id: 1, start: day(1), end: day(10)},
+		2: {id: 2, start: day(5)},
+		3: {id: 3, end: day(5)},
+		4: {id: 4},
+	}
+}
+
+func rowWindow(p *windowRow) Window {
+	return Window{Start: p.start, End: p.end}
+}
+
+func TestWindowIndexSegment(t *testing.T) {
+	idx, err := NewWindowIndex("Test", windowRows(), rowWindow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		now  time.Time
+		want int
+	}{
+		{"before all", day(1).Add(-time.Second), 0},
+		{"at first bound", day(1), 1},
+		{"inside", day(3), 1},
+		{"at second bound", day(5), 2},
+		{"at last bound", day(10), 3},
+		{"after all", day(20), 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := idx.segment(tt.now); got != tt.want {
+				t.Errorf("segment(%v) = %d, want %d", tt.now, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWindowIndexActiveAndNext(t *testing.T) {
+	idx, err := NewWindowIndex("Test", windowRows(), rowWindow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		now    time.Time
+		active []int
+		next   time.Time
+	}{
+		{"before start", day(1).Add(-time.Second), []int{3, 4}, day(1)},
+		{"start is inclusive", day(1), []int{1, 3, 4}, day(5)},
+		{"end is exclusive", day(5), []int{1, 2, 4}, day(10)},
+		{"last segment", day(10), []int{2, 4}, time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ids []int
+			for _, v := range idx.Active(tt.now) {
+				ids = append(ids, v.id)
+			}
+			if !slices.Equal(ids, tt.active) {
+				t.Errorf("Active(%v) = %v, want %v", tt.now, ids, tt.active)
+			}
+			if got := idx.Next(tt.now); !got.Equal(tt.next) {
+				t.Errorf("Next(%v) = %v, want %v", tt.now, got, tt.next)
+			}
+		})
+	}
+}
+
+func TestWindowIndexBadWindow(t *testing.T) {
+	m := map[int]*windowRow{1: {id: 1, start: day(5), end: day(5)}}
+	if _, err := NewWindowIndex("Test", m, rowWindow); err == nil {
+		t.Fatal("want error for an empty window")
+	}
+	var idx WindowIndex[windowRow]
+	if got := idx.Active(day(1)); got != nil {
+		t.Errorf("zero index Active = %v, want nil", got)
+	}
+	if got := idx.Next(day(1)); !got.IsZero() {
+		t.Errorf("zero index Next = %v, want zero", got)
+	}
+}
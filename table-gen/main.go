@@ -6,12 +6,41 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 )
 
-var basePkg = flag.String("base", "mmo_server/pkg", "base import path")
+var (
+	rtPkg        = flag.String("rt", "github.com/colakuma/server-tool/table-gen/gtrt", "gtrt runtime import path")
+	snapshotMode = flag.Bool("snapshot", false, "publish all tables through one Snapshot")
+
+	// 生成代码不再依赖业务仓库的包，保留该参数兼容旧的生成脚本
+	_ = flag.String("base", "mmo_server/pkg", "deprecated: ignored, generated code imports the -rt runtime")
+)
+
+// commands 子命令，各自解析自己的参数，不带子命令时生成表代码
+var commands = map[string]func(args []string) error{
+	"xlsx": runXlsx,
+	"new":  runNew,
+	"lint": runLint,
+	"diff": runDiff,
+}
 
 func main() {
+	if len(os.Args) > 1 {
+		if cmd, ok := commands[os.Args[1]]; ok {
+			if err := cmd(os.Args[2:]); err != nil {
+				fmt.Printf("%s failed: %v\n", os.Args[1], err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
 	flag.Parse()
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "base" {
+			fmt.Println("warning: -base is deprecated and ignored")
+		}
+	})
 
 	// 步骤3: 生成table.go和table_after_load.go
 	if err := step3(); err != nil {
@@ -22,9 +51,13 @@ func main() {
 	fmt.Println("Table generation completed successfully")
 }
 
+// genFiles 生成的文件，生成前改名为xxx2.go，完成后删除
+var genFiles = []string{"table.go", "table_after_load.go", "table_load.go", "table_snapshot.go", "table_notify.go"}
+
 func step3() error {
-	os.Rename("./table.go", "./table2.go")
-	os.Rename("./table_after_load.go", "./table_after_load2.go")
+	for _, v := range genFiles {
+		os.Rename("./"+v, "./"+oldGenFile(v))
+	}
 	files, _ := enumFile(".", "c_")
 	for _, v := range files {
 		walkFile(v)
@@ -33,13 +66,32 @@ func step3() error {
 	makeTableGo()
 	// os.Remove("./var.go")
 	// os.Remove("./v_custom.go")
-	os.Remove("./table2.go")
-	os.Remove("./table_after_load2.go")
-	goFmt("table_after_load.go")
-	goFmt("table.go")
+	for _, v := range genFiles {
+		os.Remove("./" + oldGenFile(v))
+		if _, err := os.Stat(v); os.IsNotExist(err) {
+			continue
+		}
+		if err := goFmt(v); err != nil {
+			return err
+		}
+	}
+	return goBuild()
+}
+
+// goBuild 编译生成后的包，生成的代码有错时不能报告成功
+func goBuild() error {
+	cmd := exec.Command("go", "build", ".")
+	cmd.Dir = "./"
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("generated code does not build: %v\n%s", err, out)
+	}
 	return nil
 }
 
+func oldGenFile(file string) string {
+	return strings.TrimSuffix(file, ".go") + "2.go"
+}
+
 func goFmt(file string) error {
 	cmd := exec.Command("gofmt", "-l", "-w", "-s", file)
 	cmd.Dir = "./"
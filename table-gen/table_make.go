@@ -3,14 +3,20 @@ package main
 import (
 	"fmt"
 	"go/ast"
+	"go/types"
 	"io"
 	"log"
 	"os"
 	"reflect"
 	"regexp"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
+	"unicode"
 	"unsafe"
+
+	"github.com/colakuma/server-tool/table-gen/gtrt"
 )
 
 const (
@@ -18,37 +24,74 @@ const (
 )
 
 type TableStructRuntime struct {
-	keyField  []*reflect.StructField
-	keyType   reflect.Kind
-	hasGetKey bool
-	varName   string
+	keyField   []*TableField
+	fields     []*TableField
+	mapKeyType string
+	hasGetKey  bool
+	varName    string
 }
 
-func (p *TableStructRuntime) GenKeyParams() (string, string, string, string, bool) {
+func (p *TableStructRuntime) getField(name string) *TableField {
+	for _, v := range p.fields {
+		if v.name == name {
+			return v
+		}
+	}
+	return nil
+}
+
+func (p *TableStructRuntime) GenKeyParams() (string, string, string, bool) {
 	if len(p.keyField) == 1 {
-		return "key " + p.keyType.String(), "", "", "", p.keyType == reflect.Int
+		return "key " + keyTypeString(p.keyField[0]), "", "", isIntKey(p.keyField[0])
 	}
 	allInt := true
 	params := make([]string, 0, len(p.keyField))
-	placeHold := make([]string, 0, len(p.keyField))
 	callParam := make([]string, 0, len(p.keyField))
 	getKeyParam := make([]string, 0, len(p.keyField))
 	for _, v := range p.keyField {
 		params = append(params, GenKeyParam(v))
-		callParam = append(callParam, firstCharLower(v.Name))
-		getKeyParam = append(getKeyParam, "p."+v.Name)
-		switch v.Type.Kind() {
-		case reflect.Int:
-			placeHold = append(placeHold, "%d")
-		case reflect.String:
-			placeHold = append(placeHold, "%s")
-			allInt = false
-		default:
-			log.Fatalf("do not support key type, edit func (p *TableStructRuntime) GenKeyParams() code plz!")
-		}
+		callParam = append(callParam, firstCharLower(v.name))
+		getKeyParam = append(getKeyParam, "p."+v.name)
+		allInt = allInt && isIntKey(v)
+	}
+	return strings.Join(params, ","), strings.Join(callParam, ","), strings.Join(getKeyParam, ","), allInt
+}
+
+// isIntKey 主键字段是否为int，多个int主键可以使用gtrt.KeyN
+func isIntKey(f *TableField) bool {
+	return types.Identical(f.vtype, types.Typ[types.Int])
+}
+
+// keyTypeString 返回主键字段的类型，主键字段已由checkKeyField检查
+func keyTypeString(f *TableField) string {
+	return types.TypeString(f.vtype, types.RelativeTo(loadSource().pkg))
+}
+
+// checkKeyField 主键需为可比较的内置类型或本包声明的类型
+func checkKeyField(ts *TableStruct, f *TableField) {
+	if f.vtype == nil || f.vtype == types.Typ[types.Invalid] {
+		log.Fatalf("key field %s.%s: can not resolve type %s", ts.typeName, f.name, f.typ)
+	}
+	if !types.Comparable(f.vtype) {
+		log.Fatalf("key field %s.%s: type %s is not comparable", ts.typeName, f.name, f.typ)
+	}
+	if n, ok := f.vtype.(*types.Named); ok && n.Obj().Pkg() != loadSource().pkg {
+		log.Fatalf("key field %s.%s: type %s must be a builtin type or declared in this package", ts.typeName, f.name, f.typ)
+	}
+}
 
+// useKeyStruct 多字段主键除2~3个int字段使用gtrt.KeyN外，其余生成专用的key结构体
+func (p *TableStructRuntime) useKeyStruct(allIntKey bool) bool {
+	return len(p.keyField) > 1 && !(allIntKey && len(p.keyField) <= 3)
+}
+
+// genKeyStructFields 生成key结构体的字段定义
+func (p *TableStructRuntime) genKeyStructFields() string {
+	lst := make([]string, 0, len(p.keyField))
+	for _, v := range p.keyField {
+		lst = append(lst, v.name+" "+keyTypeString(v))
 	}
-	return strings.Join(params, ","), strings.Join(placeHold, "_"), strings.Join(callParam, ","), strings.Join(getKeyParam, ","), allInt
+	return strings.Join(lst, "\n\t")
 }
 
 func firstCharLower(p string) string {
@@ -59,18 +102,161 @@ func firstCharLower(p string) string {
 	return tmp
 }
 
-func GenKeyParam(p *reflect.StructField) string {
-	return firstCharLower(p.Name) + " " + p.Type.String()
+func GenKeyParam(p *TableField) string {
+	return firstCharLower(p.name) + " " + keyTypeString(p)
 }
 
 type TableStruct struct {
 	typeName string
-	csv      string
-	excel    string
+	csv      []string // 可以有多个，支持glob
+	excel    []string
 	depend   []string
+	groups   []*groupSpec
+	customs  [customMax][]*customSpec
+	window   *windowSpec
 	TableStructRuntime
 }
 
+// TableField 结构体字段信息
+type TableField struct {
+	name  string
+	typ   string            // 字段类型源码
+	vtype types.Type        // 类型检查结果，可能为nil
+	attrs map[string]string // gtable标签属性
+}
+
+// tableFiles 返回表对应的数据文件，用于热加载匹配，可以是glob
+func (p *TableStruct) tableFiles() []string {
+	lst := make([]string, 0, len(p.excel)+len(p.csv))
+	for _, v := range p.excel {
+		file, _ := excelSheet(v)
+		lst = append(lst, file)
+	}
+	return append(lst, p.csvFiles()...)
+}
+
+// csvFiles 返回加载的csv文件，可以是glob，未配置@csv时由@excel文件名推导
+func (p *TableStruct) csvFiles() []string {
+	if len(p.csv) > 0 {
+		return p.csv
+	}
+	if len(p.excel) == 0 {
+		return []string{gtrt.CSVName("", "")}
+	}
+	lst := make([]string, 0, len(p.excel))
+	for _, v := range p.excel {
+		lst = append(lst, gtrt.CSVName(v, ""))
+	}
+	return lst
+}
+
+// excelSheet 拆分 @excel file.xlsx:Sheet，未指定工作表时sheet为空
+func excelSheet(excel string) (file, sheet string) {
+	file, sheet, _ = strings.Cut(excel, ":")
+	return file, sheet
+}
+
+func (p *TableField) hasAttr(name string) bool {
+	_, ok := p.attrs[name]
+	return ok
+}
+
+const (
+	tagFlag     = iota // 不带值，如 key
+	tagValue           // 必须带值，如 min=1
+	tagOptional        // 可带可不带，如 enum 和 enum=ElemType
+)
+
+// fieldTags gtable标签支持的属性
+var fieldTags = map[string]int{
+	"key": tagFlag, "group": tagFlag, "-": tagFlag, "json": tagFlag, "required": tagFlag, "unique": tagFlag, "i18n": tagFlag, "noenum": tagFlag,
+	"sep": tagValue, "kv": tagValue, "default": tagValue, "col": tagValue, "min": tagValue, "max": tagValue,
+	"oneof": tagValue, "regex": tagValue, "ref": tagValue, "len": tagValue, "len<": tagValue, "len>": tagValue,
+	"enum": tagOptional, "weight": tagOptional,
+}
+
+// parseFieldTag 解析字段的gtable标签，如 `gtable:"key,group"`。
+// 属性以逗号分隔，值含逗号时用单引号括起，如 oneof='a,b'；regex的值不加引号时取到标签末尾。
+// 长度约束写作 len=N、len<N、len<=N、len>N、len>=N，解析后统一为 len、len<(不超过)、len>(不少于)
+func parseFieldTag(typeName string, field *ast.Field) map[string]string {
+	attrs := make(map[string]string)
+	if field.Tag == nil {
+		return attrs
+	}
+	tag := reflect.StructTag(strings.Trim(field.Tag.Value, "`")).Get("gtable")
+	name := typeName + "." + field.Names[0].Name
+	for tag != "" {
+		var attr string
+		attr, tag = nextTagAttr(tag)
+		if attr == "" {
+			continue
+		}
+		k, v, hasValue := strings.Cut(attr, "=")
+		if strings.HasPrefix(attr, "len<") || strings.HasPrefix(attr, "len>") {
+			k, v, hasValue = lengthTag(name, attr)
+		}
+		kind, ok := fieldTags[k]
+		if !ok {
+			log.Fatalf("%s: unknown gtable attribute %q", name, attr)
+		}
+		if kind == tagValue && !hasValue || kind == tagFlag && hasValue {
+			log.Fatalf("%s: bad gtable attribute %q", name, attr)
+		}
+		if _, ok := attrs[k]; ok {
+			log.Fatalf("%s: duplicate gtable attribute %q", name, k)
+		}
+		attrs[k] = unquoteTagValue(v)
+	}
+	return attrs
+}
+
+// nextTagAttr 切出第一个属性，跳过单引号中的逗号，regex=后没有引号时取到标签末尾
+func nextTagAttr(tag string) (string, string) {
+	tag = strings.TrimLeft(tag, " ,")
+	if strings.HasPrefix(tag, "regex=") && !strings.HasPrefix(tag, "regex='") {
+		return strings.TrimSpace(tag), ""
+	}
+	quoted := false
+	for i, c := range tag {
+		switch {
+		case c == '\'':
+			quoted = !quoted
+		case c == ',' && !quoted:
+			return strings.TrimSpace(tag[:i]), tag[i+1:]
+		}
+	}
+	return strings.TrimSpace(tag), ""
+}
+
+// unquoteTagValue 去掉值两侧的单引号
+func unquoteTagValue(v string) string {
+	if len(v) >= 2 && v[0] == '\'' && v[len(v)-1] == '\'' {
+		return v[1 : len(v)-1]
+	}
+	return v
+}
+
+// lengthTag 把 len<N、len<=N、len>N、len>=N 转为 len< 和 len> 的上下限
+func lengthTag(name, attr string) (string, string, bool) {
+	ops := []struct {
+		op, key string
+		delta   int
+	}{{"len<=", "len<", 0}, {"len>=", "len>", 0}, {"len<", "len<", -1}, {"len>", "len>", 1}}
+	for _, v := range ops {
+		s, ok := strings.CutPrefix(attr, v.op)
+		if !ok {
+			continue
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil || n+v.delta < 0 {
+			log.Fatalf("%s: bad gtable attribute %q", name, attr)
+		}
+		return v.key, strconv.Itoa(n + v.delta), true
+	}
+	log.Fatalf("%s: unknown gtable attribute %q", name, attr)
+	return "", "", false
+}
+
 var (
 	tables   map[string]*TableStruct
 	tagReg   *regexp.Regexp
@@ -85,50 +271,54 @@ func init() {
 	feildReg = regexp.MustCompile(`@(.+)[^\r\n]`)
 }
 
-func GetKeyType(kind reflect.Kind, allIntKey bool, keySize int) string {
+func GetKeyType(ts *TableStruct, allIntKey bool) string {
+	keySize := len(ts.keyField)
+	if keySize == 1 {
+		return keyTypeString(ts.keyField[0])
+	}
 	if allIntKey {
 		switch keySize {
-		case 1:
-			return kind.String()
 		case 2:
-			return "tools.Key2"
+			return "gtrt.Key2"
 		case 3:
-			return "tools.Key3"
-		default:
-			return "string"
+			return "gtrt.Key3"
 		}
 	}
-	return kind.String()
+	return fmt.Sprintf(keyStructName, ts.typeName)
 }
 
 func makeTableStructStuff(ts *TableStruct, output map[string]string) {
 	fillKey(ts)
 
-	p, h, c, getKey, allIntKey := ts.GenKeyParams()
+	p, c, getKey, allIntKey := ts.GenKeyParams()
+	ts.mapKeyType = GetKeyType(ts, allIntKey)
 	key := ""
-	if h != "" {
-		if allIntKey {
-			key = fmt.Sprintf(keyMake2, len(ts.keyField), c)
+	if c != "" {
+		if ts.useKeyStruct(allIntKey) {
+			key = fmt.Sprintf(keyMakeStruct, ts.mapKeyType, c)
+			output["mapType"] += fmt.Sprintf(keyStructType, ts.mapKeyType, ts.genKeyStructFields())
 		} else {
-			key = fmt.Sprintf(keyMake, h, c)
+			key = fmt.Sprintf(keyMake2, len(ts.keyField), c)
 		}
 	}
 
-	output["mapType"] += fmt.Sprintf(mapType, ts.typeName, GetKeyType(ts.keyType, allIntKey, len(ts.keyField)), ts.typeName)
+	output["mapType"] += fmt.Sprintf(mapType, ts.typeName, ts.mapKeyType, ts.typeName)
 	output["mapVar"] += fmt.Sprintf(mapVar, ts.varName, ts.typeName)
-	output["loadFunc"] += fmt.Sprintf(loadFunc, ts.typeName, ts.typeName, ts.excel, ts.typeName, ts.csv, ts.typeName, ts.excel, ts.csv, ts.typeName)
+	files := strings.TrimSuffix(strings.TrimPrefix(stringSlice(ts.csvFiles()), "[]string{"), "}")
+	output["loadFunc"] += fmt.Sprintf(loadFunc, ts.typeName, ts.typeName, ts.typeName, ts.typeName, ts.typeName, ts.typeName, files, ts.typeName, ts.typeName, ts.typeName, ts.typeName)
 	output["getFunc"] += fmt.Sprintf(getFunc, ts.typeName, p, ts.typeName, key, ts.varName)
 	output["getAllFunc"] += fmt.Sprintf(getAllFunc, ts.typeName, ts.typeName, ts.varName)
-	output["callLoad"] += fmt.Sprintf(callLoad, ts.typeName)
+	output["stagedFunc"] += fmt.Sprintf(stagedGetFunc, ts.typeName, p, ts.typeName, key, ts.varName, ts.typeName, ts.typeName, ts.varName)
+	snapshotMap(ts, p, key, output)
 	if ts.hasGetKey {
 		if len(ts.keyField) > 1 {
-			if allIntKey {
-				output["getKey"] += fmt.Sprintf(getKeyNPattern, ts.typeName, len(ts.keyField), getKey)
+			if ts.useKeyStruct(allIntKey) {
+				output["getKey"] += fmt.Sprintf(getKeyStructPattern, ts.typeName, ts.mapKeyType, getKey)
 			} else {
-				output["getKey"] += fmt.Sprintf(getKeyStringPattern, ts.typeName, h, getKey)
+				output["getKey"] += fmt.Sprintf(getKeyNPattern, ts.typeName, len(ts.keyField), getKey)
 			}
 		} else {
-			output["getKey"] += fmt.Sprintf(getKeyPattern, ts.typeName, ts.keyField[0].Name)
+			output["getKey"] += fmt.Sprintf(getKeyPattern, ts.typeName, ts.keyField[0].name)
 		}
 	}
 }
@@ -143,8 +333,7 @@ func StringBytes(s string) []byte {
 }
 
 func WriteTableGo(output map[string]string, filePath string) {
-	loadAll := fmt.Sprintf(loadAllFunc, output["callLoad"])
-	context := fmt.Sprintf(fileContext, *basePkg, output["mapType"], output["mapVar"], loadAll, output["loadFunc"], output["getKey"], output["getFunc"], output["getAllFunc"])
+	context := fmt.Sprintf(fileContext, *rtPkg, gtrt.Version, gtrt.Version, output["mapType"], output["mapVar"], output["register"], loadAllFunc, output["loadFunc"], output["getKey"], output["getFunc"], output["getAllFunc"], output["stagedFunc"])
 
 	os.WriteFile(filePath, StringBytes(context), 0o600|0o064)
 }
@@ -163,12 +352,11 @@ func fillKey(ts *TableStruct) {
 		log.Fatalf("struct type not exits3:%s! error: %v", realName, err)
 	}
 
-	hasId := false
-	ts.keyField = make([]*reflect.StructField, 0)
-
-	var defaultSf *reflect.StructField
+	var defaultKey *TableField
+	ts.keyField = make([]*TableField, 0)
 
 	// 从结构体定义中解析字段
+	ts.fields = make([]*TableField, 0, len(structType.Fields.List))
 	for _, field := range structType.Fields.List {
 		// 跳过匿名/嵌入字段
 		if len(field.Names) == 0 {
@@ -177,62 +365,40 @@ func fillKey(ts *TableStruct) {
 		fieldName := field.Names[0].Name
 		fieldType := field.Type
 
+		// 检查字段标签
+		tf := &TableField{
+			name:  fieldName,
+			typ:   types.ExprString(fieldType),
+			vtype: loadSource().info.TypeOf(fieldType),
+			attrs: parseFieldTag(realName, field),
+		}
+		ts.fields = append(ts.fields, tf)
+		markEnum(ts, tf)
+
 		// 统一转成小写比较
 		if strings.ToLower(fieldName) == defaultKeyName {
-			hasId = true
-			// 构建默认key字段
-
-			defaultSf = &reflect.StructField{
-				Name: fieldName,
-				Type: getReflectTypeFromAst(fieldType),
-				Tag:  reflect.StructTag(""),
-			}
+			defaultKey = tf
 		}
-
-		// 检查字段标签
-		if field.Tag != nil {
-			tag := field.Tag.Value
-			// 更完善的标签解析逻辑
-			if strings.HasPrefix(tag, "`gtable:") {
-				// 去除反引号
-				tag = strings.Trim(tag, "`")
-				// 提取gtable标签内容
-				tagStr := strings.TrimPrefix(tag, `gtable:`)
-				tagStr = strings.Trim(tagStr, `"`)
-				// 分割标签属性
-				attrs := strings.Split(tagStr, ",")
-				for _, attr := range attrs {
-					attr = strings.TrimSpace(attr)
-					if strings.Contains(attr, "key") {
-						// 构建 StructField 对象
-						sf := &reflect.StructField{
-							Name: fieldName,
-							Type: getReflectTypeFromAst(fieldType),
-							Tag:  reflect.StructTag(tag),
-						}
-						ts.keyField = append(ts.keyField, sf)
-						break
-					}
-				}
-			}
+		if tf.hasAttr("key") {
+			ts.keyField = append(ts.keyField, tf)
+		}
+		if tf.hasAttr("group") && !slices.ContainsFunc(ts.groups, func(g *groupSpec) bool { return g.field == fieldName }) {
+			ts.groups = append(ts.groups, &groupSpec{field: fieldName})
 		}
 	}
 
 	if len(ts.keyField) == 0 {
-		if !hasId {
+		if defaultKey == nil {
 			log.Printf("1 struct %s has not specific key or default key [%s]!", realName, defaultKeyName)
 			fatal = true
 			return
 		}
-		ts.keyField = append(ts.keyField, defaultSf)
+		ts.keyField = append(ts.keyField, defaultKey)
 	} else {
 		ts.hasGetKey = true
 	}
-
-	if len(ts.keyField) > 1 {
-		ts.keyType = reflect.String
-	} else {
-		ts.keyType = ts.keyField[0].Type.Kind()
+	for _, v := range ts.keyField {
+		checkKeyField(ts, v)
 	}
 
 	ts.varName = fmt.Sprintf(varName, ts.typeName)
@@ -281,31 +447,110 @@ func walkFile(path string) {
 }
 
 func parseTag(tagStr string) []string {
-	lst := strings.Split(tagStr, " ")
-	if len(lst) != 2 {
+	lst := splitTagFields(tagStr)
+	if len(lst) < 2 {
 		fmt.Printf("incorrect tag string:%s\n", tagStr)
 	}
 	return lst
 }
 
+// splitTagFields 按空白切分注解，双引号内的空白不切分
+func splitTagFields(s string) []string {
+	lst := make([]string, 0)
+	var sb strings.Builder
+	quoted, escaped := false, false
+	for _, c := range s {
+		switch {
+		case escaped:
+			escaped = false
+		case quoted && c == '\\':
+			escaped = true
+		case c == '"':
+			quoted = !quoted
+		case !quoted && unicode.IsSpace(c):
+			if sb.Len() > 0 {
+				lst = append(lst, sb.String())
+				sb.Reset()
+			}
+			continue
+		}
+		sb.WriteRune(c)
+	}
+	if sb.Len() > 0 {
+		lst = append(lst, sb.String())
+	}
+	return lst
+}
+
+// parseTagOptions 解析注解中 k=v 形式的可选参数，v可以用双引号包裹
+func parseTagOptions(tokens []string) map[string]string {
+	opts := make(map[string]string, len(tokens))
+	for _, v := range tokens {
+		k, val, ok := strings.Cut(v, "=")
+		if !ok {
+			fmt.Printf("incorrect tag option:%s\n", v)
+			continue
+		}
+		if strings.HasPrefix(val, `"`) {
+			s, err := strconv.Unquote(val)
+			if err != nil {
+				fmt.Printf("incorrect tag option:%s\n", v)
+				continue
+			}
+			val = s
+		}
+		opts[k] = val
+	}
+	return opts
+}
+
+// splitFileList 解析 @csv/@excel 的文件列表，空格或逗号分隔
+func splitFileList(tokens []string) []string {
+	lst := make([]string, 0, len(tokens))
+	for _, v := range tokens {
+		for _, f := range strings.Split(v, ",") {
+			if f != "" {
+				lst = append(lst, f)
+			}
+		}
+	}
+	return lst
+}
+
 func parseTags(tagStr string) {
 	lst := feildReg.FindAllStringSubmatch(string(tagStr), -1)
 	if len(lst) == 0 {
 		return
 	}
 	typName := string(lst[0][0][1:])
+	// @check 标注的是lint检查函数，不是表
+	if f := strings.Fields(typName); len(f) > 0 && f[0] == "check" {
+		return
+	}
 	name := strings.ToLower(typName)
 	t := &TableStruct{typeName: typName}
 	lst = lst[1:]
 	for _, v := range lst {
 		tags := parseTag(v[0])
+		if len(tags) < 2 {
+			continue
+		}
 		switch tags[0][1:] {
 		case "csv":
-			t.csv = tags[1] // strings.ToLower(tags[1])
+			t.csv = append(t.csv, splitFileList(tags[1:])...)
 		case "excel":
-			t.excel = tags[1]
+			t.excel = append(t.excel, splitFileList(tags[1:])...)
 		case "depend":
 			t.depend = append(t.depend, strings.Split(tags[1], "|")...)
+		case "keySlice", "valueSlice", "filterMap":
+			i := customIndex(tags[0][1:])
+			opts := parseTagOptions(tags[2:])
+			t.customs[i] = append(t.customs[i], &customSpec{name: tags[1], sort: parseSortSpec(opts["sort"]), filter: opts["filter"]})
+		case "window":
+			t.window = parseWindowSpec(parseTagOptions(tags[1:]))
+		case "group":
+			opts := parseTagOptions(tags[2:])
+			t.groups = append(t.groups, &groupSpec{field: tags[1], sort: parseSortSpec(opts["sort"])})
 		}
 	}
 	tables[name] = t
@@ -332,7 +577,12 @@ func makeTableGo() {
 	}
 
 	makeCustom(lst, output)
+	makeLoad(lst)
+	makeNotify(lst)
 	WriteTableGo(output, "./table.go")
+	if *snapshotMode {
+		WriteSnapshotGo(output, "./table_snapshot.go")
+	}
 }
 
 func makeVar() {
@@ -344,24 +594,3 @@ func makeVar() {
 
 	WriteVarGo(output, output2, "./var.go")
 }
-
-// 新增辅助函数：从AST类型节点获取reflect.Type
-func getReflectTypeFromAst(expr ast.Expr) reflect.Type {
-	switch t := expr.(type) {
-	case *ast.Ident:
-		switch t.Name {
-		case "int":
-			return reflect.TypeOf(0)
-		case "string":
-			return reflect.TypeOf("")
-		// 添加更多类型支持...
-		default:
-			return reflect.TypeOf((*interface{})(nil)).Elem()
-		}
-	case *ast.StarExpr:
-		return reflect.PtrTo(getReflectTypeFromAst(t.X))
-	// 添加更多类型支持...
-	default:
-		return reflect.TypeOf((*interface{})(nil)).Elem()
-	}
-}
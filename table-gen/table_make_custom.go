@@ -7,9 +7,10 @@ import (
 	"go/token"
 	"log"
 	"os"
-	"path/filepath"
 	"reflect"
 	"slices"
+	"sort"
+	"strconv"
 	"strings"
 )
 
@@ -52,6 +53,52 @@ type IAfterLoad interface {
 	afterLoad(any)
 }
 
+// ICheck 发布前执行的校验，签名为 check(s Staged, m any) error，Staged是生成代码中的类型，
+// 通过它读取其他表本次加载的数据，返回错误时本次加载的数据全部丢弃
+type ICheck interface {
+	check(any, any) error
+}
+
+// customSpec 注解声明的自定义集合选项，如 @valueSlice Shop sort=-Weight,Id
+// 带filter时无需手写方法，如 @valueSlice Shop filter="Type==3"
+type customSpec struct {
+	name   string
+	sort   []sortField
+	filter string
+	used   bool
+}
+
+// customIndex 根据注解名返回自定义集合的下标
+func customIndex(name string) int {
+	for i, v := range tabelCustomPattern {
+		if v.implPattern == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func (p *TableStruct) findCustom(i int, name string) *customSpec {
+	for _, v := range p.customs[i] {
+		if v.name == name {
+			return v
+		}
+	}
+	return nil
+}
+
+// makeSliceSort 生成注解声明的稳定排序，keySlice按key对应的行比较
+func makeSliceSort(ts *TableStruct, i int, varTmp string, fields []sortField) string {
+	a, b := fmt.Sprintf("%s[i]", varTmp), fmt.Sprintf("%s[j]", varTmp)
+	switch i {
+	case 0:
+		a, b = fmt.Sprintf("(*m)[%s]", a), fmt.Sprintf("(*m)[%s]", b)
+	case 2:
+		log.Fatalf("@%s of %s can not be sorted", tabelCustomPattern[i].implPattern, ts.typeName)
+	}
+	return fmt.Sprintf(sliceSort, varTmp, a, b, genLess(ts, fields, "a", "b"))
+}
+
 type TabelCustomStrings struct {
 	typePattern string
 	varPattern  string
@@ -60,91 +107,136 @@ type TabelCustomStrings struct {
 	implPattern string
 }
 
-const customMax = 2
+const customMax = 3
 
 var (
 	sliceIFunc     = []reflect.Type{reflect.TypeOf((*IKeySlice)(nil)).Elem(), reflect.TypeOf((*IValueSlice)(nil)).Elem(), reflect.TypeOf((*IFilterMap)(nil)).Elem()}
 	sliceIFuncName = []reflect.Type{reflect.TypeOf((*IKeySliceName)(nil)).Elem(), reflect.TypeOf((*IValueSliceName)(nil)).Elem(), reflect.TypeOf((*IFilterMapName)(nil)).Elem()}
-	sliceIFuncSort = []reflect.Type{reflect.TypeOf((*IKeySliceSort)(nil)).Elem(), reflect.TypeOf((*IValueSliceSort)(nil)).Elem(), reflect.TypeOf((*IFilterMapName)(nil)).Elem()}
-	sliceFuncName  = []string{"keySliceName", "valueSliceName", ""}
+	sliceIFuncSort = []reflect.Type{reflect.TypeOf((*IKeySliceSort)(nil)).Elem(), reflect.TypeOf((*IValueSliceSort)(nil)).Elem(), nil}
+	sliceFuncName  = []string{"keySliceName", "valueSliceName", "filterMapName"}
 
 	tabelCustomPattern = []TabelCustomStrings{
 		{
 			typePattern: "%sKeySlice\t%s\n\t",
-			varPattern:  "sliceKey%s\tatomic.Pointer[%sKeySlice]\n\t",
+			varPattern:  "sliceKey%s\tgtrt.Var[%sKeySlice]\n\t",
 			varName:     "sliceKey%s",
 			typeName:    "%sKeySlice",
 			implPattern: "keySlice",
 		},
 		{
 			typePattern: "%sValueSlice\t%s\n\t",
-			varPattern:  "sliceValue%s\tatomic.Pointer[%sValueSlice]\n\t",
+			varPattern:  "sliceValue%s\tgtrt.Var[%sValueSlice]\n\t",
 			varName:     "sliceValue%s",
 			typeName:    "%sValueSlice",
 			implPattern: "valueSlice",
 		},
-		// {
-		// 	typePattern: "%sFilterMap\t%s\n\t",
-		// 	varPattern:  "mapFilter%s\tatomic.Value\n\t",
-		// 	varName:     "mapFilter%s",
-		// 	implPattern: "filterMap",
-		// },
+		{
+			typePattern: "%sFilterMap\t%s\n\t",
+			varPattern:  "mapFilter%s\tgtrt.Var[%sFilterMap]\n\t",
+			varName:     "mapFilter%s",
+			typeName:    "%sFilterMap",
+			implPattern: "filterMap",
+		},
 	}
 )
 
 func getCustomElemType(ts *TableStruct, i int) string {
 	switch i {
 	case 0:
-		return "[]" + ts.keyType.String()
+		return "[]" + ts.mapKeyType
 	case 1:
 		return "[]*" + ts.typeName
-		// case 2:
-		// 	return fmt.Sprintf("map[%s]*%s", ts.keyType.String(), ts.typeName)
+	case 2:
+		return fmt.Sprintf("map[%s]*%s", ts.mapKeyType, ts.typeName)
 	}
 	return ts.typeName
 }
 
-func MakeImpl(ts *TableStruct, varTmp, varName, name string, i, j int, hasSort bool, _make, _op, _append, _sort *[]string) {
+func MakeImpl(ts *TableStruct, varTmp, varName, name, cond string, i, j int, hasSort bool, _make, _op, _append, _stage, _sort *[]string) {
 	*_make = append(*_make, fmt.Sprintf(afterMake, varTmp, fmt.Sprintf(tabelCustomPattern[i].typeName, name))) // getCustomElemType(ts, i)))
+	if cond == "" {
+		cond = fmt.Sprintf(afterCond, tabelCustomPattern[i].implPattern, j)
+	}
 	switch i {
 	case 0:
-		*_op = append(*_op, fmt.Sprintf(afterOp, tabelCustomPattern[i].implPattern, j, varTmp, varTmp, "k"))
+		*_op = append(*_op, fmt.Sprintf(afterOp, cond, varTmp, varTmp, "k"))
 	case 1:
-		*_op = append(*_op, fmt.Sprintf(afterOp, tabelCustomPattern[i].implPattern, j, varTmp, varTmp, "v"))
-		// case 2:
-		// 	*_op = append(*_op, fmt.Sprintf(afterOpMap, tabelCustomPattern[i].implPattern, j, varTmp))
+		*_op = append(*_op, fmt.Sprintf(afterOp, cond, varTmp, varTmp, "v"))
+	case 2:
+		*_op = append(*_op, fmt.Sprintf(afterOpMap, cond, varTmp))
 	}
 
-	*_append = append(*_append, fmt.Sprintf(afterAppend, varName, varTmp))
+	appendPublish(varName, varTmp, _append, _stage)
 	if hasSort {
 		*_sort = append(*_sort, fmt.Sprintf(afterSort, ts.typeName, tabelCustomPattern[i].implPattern, varTmp, j))
 	}
 }
 
-func makeCustomGet(i int, name, varName, typeName string, getMap map[string]string) {
-	if i < 2 {
-		getMap["getAllFunc"] += fmt.Sprintf(getCustomFunc, name, typeName, varName)
+// appendPublish 生成构建后暂存集合、发布时替换集合的代码
+func appendPublish(varName, varTmp string, _append, _stage *[]string) {
+	*_stage = append(*_stage, fmt.Sprintf(afterStage, varName, varTmp))
+	*_append = append(*_append, fmt.Sprintf(afterAppend, varName, varTmp))
+}
+
+// stringSlice 生成字符串切片字面量，空时为nil
+func stringSlice(lst []string) string {
+	if len(lst) == 0 {
+		return "nil"
+	}
+	quoted := make([]string, 0, len(lst))
+	for _, v := range lst {
+		quoted = append(quoted, strconv.Quote(v))
 	}
+	return "[]string{" + strings.Join(quoted, ", ") + "}"
 }
 
-// 新增函数：从源码解析结构体定义
-func parseStructFromSource(structName string) (*ast.StructType, error) {
-	// 1. 读取所有c_*.go文件
-	files, err := filepath.Glob("./c_*.go")
+// compileFilter 将注解中的过滤表达式编译为Go代码，结构体字段名替换为 v.字段名
+func compileFilter(ts *TableStruct, filter string) string {
+	expr, err := parser.ParseExpr(filter)
 	if err != nil {
-		return nil, fmt.Errorf("failed to glob files: %v", err)
+		log.Fatalf("invalid filter %q of %s: %v", filter, ts.typeName, err)
 	}
 
-	// 2. 解析每个文件查找目标结构体
-	fset := token.NewFileSet()
-	for _, file := range files {
-		f, err := parser.ParseFile(fset, file, nil, parser.ParseComments)
-		if err != nil {
-			log.Printf("warning: failed to parse file %s: %v", file, err)
-			continue
+	offsets := make([]int, 0)
+	var visit func(n ast.Node) bool
+	visit = func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.SelectorExpr:
+			// 只处理选择器左侧，如 Type == cc.ItemWeapon 中的cc不是字段
+			ast.Inspect(x.X, visit)
+			return false
+		case *ast.Ident:
+			if ts.getField(x.Name) != nil {
+				offsets = append(offsets, int(x.Pos())-1)
+			}
 		}
+		return true
+	}
+	ast.Inspect(expr, visit)
+	sort.Ints(offsets)
+
+	var sb strings.Builder
+	last := 0
+	for _, v := range offsets {
+		sb.WriteString(filter[last:v])
+		sb.WriteString("v.")
+		last = v
+	}
+	sb.WriteString(filter[last:])
+	return "(" + sb.String() + ")"
+}
+
+func makeCustomGet(i int, name, varName, typeName string, getMap map[string]string) {
+	if i < customMax {
+		getMap["getAllFunc"] += fmt.Sprintf(getCustomFunc, name, typeName, varName)
+		snapshotCustom(name, typeName, varName, getMap)
+	}
+}
 
-		// 3. 遍历文件中的声明
+// 新增函数：从源码解析结构体定义
+func parseStructFromSource(structName string) (*ast.StructType, error) {
+	// 1. 遍历所有c_*.go文件的声明查找目标结构体
+	for _, f := range loadSource().cFiles() {
 		for _, decl := range f.Decls {
 			genDecl, ok := decl.(*ast.GenDecl)
 			if !ok || genDecl.Tok != token.TYPE {
@@ -190,56 +282,118 @@ func makeCustomOne(ts *TableStruct, output map[string]string, getMap map[string]
 	if len(methodNames) > 0 && slices.Contains(methodNames, "valueSlice") {
 		hasValueSlice = true
 	}
+	hasFilterMap := false
+	if len(methodNames) > 0 && slices.Contains(methodNames, "filterMap") {
+		hasFilterMap = true
+	}
 	hasAfterLoad := false
 	if len(methodNames) > 0 && slices.Contains(methodNames, "afterLoad") {
 		hasAfterLoad = true
 	}
+	hasCheck := slices.Contains(methodNames, "check")
 
 	_make := make([]string, 0)
 	_op := make([]string, 0)
 	_append := make([]string, 0)
+	_stage := make([]string, 0)
 	_sort := make([]string, 0)
 
 	varIndex := 1
 	hasK := false
 	for i := 0; i < customMax; i++ {
+		// 注解中带filter的集合无需手写方法
+		declared := make([]*customSpec, 0)
+		for _, spec := range ts.customs[i] {
+			if spec.filter != "" {
+				declared = append(declared, spec)
+			}
+		}
+
 		// 检查是否实现接口方法
-		if (i == 0 && !hasKeySlice) || (i == 1 && !hasValueSlice) {
+		hasMethod := (i == 0 && hasKeySlice) || (i == 1 && hasValueSlice) || (i == 2 && hasFilterMap)
+		if !hasMethod && len(declared) == 0 {
 			continue
 		}
 
-		if i == 0 {
+		if i != 1 {
 			hasK = true
 		}
 
 		// 获取自定义名称
-		names := getCustomNamesFromSource(ts.typeName, i)
+		var names []string
 		isDefaultName := true
-		if len(names) == 0 {
-			isDefaultName = false
-			names = append(names, ts.typeName)
+		if hasMethod {
+			names = getCustomNamesFromSource(ts.typeName, i)
+			if len(names) == 0 {
+				isDefaultName = false
+				names = append(names, ts.typeName)
+			}
 		}
 
+		// map无序，filterMap不支持排序
 		bHasSort := false
-		if len(methodNames) > 0 && slices.Contains(methodNames, fmt.Sprintf("%sSort", tabelCustomPattern[i].implPattern)) {
+		if i != 2 && len(methodNames) > 0 && slices.Contains(methodNames, fmt.Sprintf("%sSort", tabelCustomPattern[i].implPattern)) {
 			bHasSort = true
 		}
 
 		for j, v := range names {
+			spec := ts.findCustom(i, v)
+			if spec != nil && spec.filter != "" {
+				log.Fatalf("@%s %s of %s is declared by both filter and %s method", tabelCustomPattern[i].implPattern, v, ts.typeName, tabelCustomPattern[i].implPattern)
+			}
 			output["typePattern"] += fmt.Sprintf(tabelCustomPattern[i].typePattern, v, getCustomElemType(ts, i))
 			output["varPattern"] += fmt.Sprintf(tabelCustomPattern[i].varPattern, v, v)
 			varTmp := fmt.Sprintf("var%d", varIndex)
 			varName := fmt.Sprintf(tabelCustomPattern[i].varName, v)
 			varIndex++
-			MakeImpl(ts, varTmp, varName, v, i, j, bHasSort, &_make, &_op, &_append, &_sort)
+			MakeImpl(ts, varTmp, varName, v, "", i, j, bHasSort, &_make, &_op, &_append, &_stage, &_sort)
 			methodName := v
 			if !isDefaultName {
 				methodName = fmt.Sprintf(tabelCustomPattern[i].typeName, v)
 			}
 			makeCustomGet(i, methodName, varName, fmt.Sprintf(tabelCustomPattern[i].typeName, v), getMap)
+
+			// 手写的xxxSort方法优先于注解声明的排序
+			if spec != nil {
+				spec.used = true
+				if len(spec.sort) > 0 && !bHasSort {
+					_sort = append(_sort, makeSliceSort(ts, i, varTmp, spec.sort))
+					addImport(output, "sort")
+				}
+			}
+		}
+
+		for _, spec := range declared {
+			v := spec.name
+			spec.used = true
+			output["typePattern"] += fmt.Sprintf(tabelCustomPattern[i].typePattern, v, getCustomElemType(ts, i))
+			output["varPattern"] += fmt.Sprintf(tabelCustomPattern[i].varPattern, v, v)
+			varTmp := fmt.Sprintf("var%d", varIndex)
+			varName := fmt.Sprintf(tabelCustomPattern[i].varName, v)
+			varIndex++
+			MakeImpl(ts, varTmp, varName, v, compileFilter(ts, spec.filter), i, 0, false, &_make, &_op, &_append, &_stage, &_sort)
+			makeCustomGet(i, v, varName, fmt.Sprintf(tabelCustomPattern[i].typeName, v), getMap)
+			if len(spec.sort) > 0 {
+				_sort = append(_sort, makeSliceSort(ts, i, varTmp, spec.sort))
+				addImport(output, "sort")
+			}
+		}
+	}
+	for i := range ts.customs {
+		for _, spec := range ts.customs[i] {
+			if !spec.used {
+				log.Fatalf("@%s %s of %s matches no %s", tabelCustomPattern[i].implPattern, spec.name, ts.typeName, tabelCustomPattern[i].implPattern)
+			}
 		}
 	}
+	makeGroups(ts, &varIndex, output, getMap, &_make, &_op, &_append, &_stage, &_sort)
+	funcs := makeWindow(ts, &varIndex, output, getMap, &_make, &_append, &_stage)
+	funcs += makeWeight(ts, &varIndex, output, getMap, &_make, &_append, &_stage)
 
+	callValidate := ""
+	if hasValidate(ts) {
+		callValidate = fmt.Sprintf(validateCall, ts.typeName, ts.typeName)
+	}
 	callStructAfterLoad := ""
 	if hasAfterLoad {
 		callStructAfterLoad = fmt.Sprintf(structAfterLoad, ts.typeName)
@@ -250,70 +404,78 @@ func makeCustomOne(ts *TableStruct, output map[string]string, getMap map[string]
 		if hasK {
 			strK = "k"
 		}
-		output["implPattern"] += fmt.Sprintf(afterFunc, ts.typeName, ts.typeName, callStructAfterLoad, strings.Join(_make, "\n"), strK, strings.Join(_op, "\n"), strings.Join(_sort, "\n\t"), ts.varName, strings.Join(_append, "\n"))
+		loop := ""
+		if len(_op) > 0 {
+			loop = fmt.Sprintf(afterLoop, strK, strings.Join(_op, "\n"))
+		}
+		output["implPattern"] += fmt.Sprintf(afterFunc, ts.typeName, ts.typeName, callValidate, ts.varName, callStructAfterLoad, strings.Join(_make, "\n"), loop, strings.Join(_sort, "\n\t"), strings.Join(_stage, "\n"), ts.varName, ts.typeName, strings.Join(_append, "\n"))
 	} else {
-		output["implPattern"] += fmt.Sprintf(afterFunc2, ts.typeName, ts.typeName, callStructAfterLoad, ts.varName)
+		output["implPattern"] += fmt.Sprintf(afterFunc2, ts.typeName, ts.typeName, callValidate, ts.varName, callStructAfterLoad, ts.varName, ts.typeName)
+	}
+
+	check := makeI18n(ts, hasCheck, output, getMap)
+	if check == "" {
+		check = "nil"
+		if hasCheck {
+			check = "check" + ts.typeName
+			output["implPattern"] += fmt.Sprintf(checkFunc, ts.typeName, ts.varName, ts.typeName)
+		}
 	}
+	output["implPattern"] += funcs
+	getMap["register"] += fmt.Sprintf(registerTable, ts.typeName, stringSlice(ts.tableFiles()), stringSlice(ts.depend), ts.typeName, check)
 }
 
-// 从源码解析获取自定义名称
+// 从源码解析获取自定义名称，无法静态确定时带上文件位置报错退出
 func getCustomNamesFromSource(structName string, i int) []string {
 	// 1. 获取接口方法名
-	var methodName string
-	switch i {
-	case 0:
-		methodName = "keySliceName"
-	case 1:
-		methodName = "valueSliceName"
-	default:
-		return make([]string, 0)
-	}
+	methodName := sliceFuncName[i]
 
 	// 2. 查找方法实现
-	files, err := filepath.Glob("./c_*.go")
-	if err != nil {
-		return make([]string, 0)
-	}
-
-	fset := token.NewFileSet()
-	for _, file := range files {
-		f, err := parser.ParseFile(fset, file, nil, parser.ParseComments)
-		if err != nil {
-			continue
-		}
-
-		// 3. 查找方法声明
+	src := loadSource()
+	for _, f := range src.cFiles() {
 		for _, decl := range f.Decls {
 			funcDecl, ok := decl.(*ast.FuncDecl)
-			if !ok || funcDecl.Recv == nil || funcDecl.Name.Name != methodName {
+			if !ok || funcDecl.Name.Name != methodName || recvName(funcDecl) != structName {
 				continue
 			}
 
-			// 4. 检查接收者类型是否匹配
-			for _, recv := range funcDecl.Recv.List {
-				starExpr, ok := recv.Type.(*ast.StarExpr)
+			pos := src.fset.Position(funcDecl.Pos())
+			if funcDecl.Body == nil {
+				log.Fatalf("%s: %s.%s has no body, can not determine names statically", pos, structName, methodName)
+			}
+
+			// 3. 对每个return语句求值，结果必须一致
+			var names []string
+			var err error
+			found := false
+			ast.Inspect(funcDecl.Body, func(n ast.Node) bool {
+				if _, ok := n.(*ast.FuncLit); ok || err != nil {
+					return false
+				}
+				stmt, ok := n.(*ast.ReturnStmt)
 				if !ok {
-					continue
+					return true
 				}
-
-				ident, ok := starExpr.X.(*ast.Ident)
-				if !ok || ident.Name != structName {
-					continue
+				if len(stmt.Results) != 1 {
+					err = fmt.Errorf("%s: bare return", src.fset.Position(stmt.Pos()))
+					return false
 				}
-
-				// 5. 解析方法体获取返回的名称列表
-				if stmt, ok := funcDecl.Body.List[0].(*ast.ReturnStmt); ok {
-					if compLit, ok := stmt.Results[0].(*ast.CompositeLit); ok {
-						var names []string
-						for _, elt := range compLit.Elts {
-							if bl, ok := elt.(*ast.BasicLit); ok {
-								names = append(names, strings.Trim(bl.Value, `"`))
-							}
-						}
-						return names
-					}
+				lst, e := src.evalStrings(stmt.Results[0])
+				if e != nil {
+					err = e
+				} else if found && !slices.Equal(lst, names) {
+					err = fmt.Errorf("%s: returns %v, differs from %v", src.fset.Position(stmt.Pos()), lst, names)
 				}
+				names, found = lst, true
+				return false
+			})
+			if err == nil && !found {
+				err = fmt.Errorf("%s: no return statement", pos)
+			}
+			if err != nil {
+				log.Fatalf("can not determine %s.%s names statically: %v", structName, methodName, err)
 			}
+			return names
 		}
 	}
 	return make([]string, 0)
@@ -329,53 +491,32 @@ func makeCustom(lst []*TableStruct, getMap map[string]string) {
 }
 
 func WriteCustomGo(output map[string]string, filePath string) {
-	context := fmt.Sprintf(customFile, output["typePattern"], output["varPattern"], output["implPattern"])
+	context := fmt.Sprintf(customFile, output["imports"], *rtPkg, output["typePattern"], output["varPattern"], output["implPattern"])
 
 	os.WriteFile(filePath, StringBytes(context), 0o600|0o064)
 }
 
+// addImport 为生成文件追加import，重复添加只保留一次
+func addImport(output map[string]string, pkg string) {
+	line := fmt.Sprintf("%q\n\t", pkg)
+	if !strings.Contains(output["imports"], line) {
+		output["imports"] += line
+	}
+}
+
 // 获取结构体的所有方法列表
 func getStructMethods(structName string) ([]string, error) {
 	// 存储找到的方法名
 	methods := make([]string, 0)
 
-	// 1. 读取所有c_*.go文件
-	files, err := filepath.Glob("./c_*.go")
-	if err != nil {
-		return nil, fmt.Errorf("failed to glob files: %v", err)
-	}
-
-	// 2. 解析每个文件查找目标结构体的方法
-	fset := token.NewFileSet()
-	for _, file := range files {
-		f, err := parser.ParseFile(fset, file, nil, parser.ParseComments)
-		if err != nil {
-			log.Printf("warning: failed to parse file %s: %v", file, err)
-			continue
-		}
-
-		// 3. 遍历文件中的声明，查找方法
+	// 遍历c_*.go文件中的方法，指针和值接收者都算
+	for _, f := range loadSource().cFiles() {
 		for _, decl := range f.Decls {
 			funcDecl, ok := decl.(*ast.FuncDecl)
-			if !ok || funcDecl.Recv == nil {
-				continue // 不是方法或没有接收者
-			}
-
-			// 4. 检查接收者类型是否匹配
-			for _, recv := range funcDecl.Recv.List {
-				// 处理指针接收者 (*StructName)
-				starExpr, isStarExpr := recv.Type.(*ast.StarExpr)
-				if isStarExpr {
-					if ident, ok := starExpr.X.(*ast.Ident); ok && ident.Name == structName {
-						methods = append(methods, funcDecl.Name.Name)
-						break
-					}
-				} else if ident, ok := recv.Type.(*ast.Ident); ok && ident.Name == structName {
-					// 处理值接收者 (StructName)
-					methods = append(methods, funcDecl.Name.Name)
-					break
-				}
+			if !ok || recvName(funcDecl) != structName {
+				continue
 			}
+			methods = append(methods, funcDecl.Name.Name)
 		}
 	}
 
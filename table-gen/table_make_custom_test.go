@@ -0,0 +1,158 @@
+package main
+
+import (
+	"go/ast"
+	"go/types"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestCompileFilter(t *testing.T) {
+	ts := &TableStruct{typeName: "Item"}
+	for _, v := range []string{"Type", "Level", "Name", "Tags"} {
+		ts.fields = append(ts.fields, &TableField{name: v})
+	}
+	tests := []struct {
+		filter string
+		want   string
+	}{
+		{"Type==3", "(v.Type==3)"},
+		{"Type == cc.Type && Level > 1", "(v.Type == cc.Type && v.Level > 1)"},
+		{`len(Tags) > 0 || Name != "x"`, `(len(v.Tags) > 0 || v.Name != "x")`},
+		{"Level%2 == 0 && !(Type == 1)", "(v.Level%2 == 0 && !(v.Type == 1))"},
+		{"Other == 1", "(Other == 1)"},
+	}
+	for _, tt := range tests {
+		if got := compileFilter(ts, tt.filter); got != tt.want {
+			t.Errorf("compileFilter(%q) = %s, want %s", tt.filter, got, tt.want)
+		}
+	}
+}
+
+func TestGenerateCustomNames(t *testing.T) {
+	generate(t, map[string]string{
+		"c_item.go": `package gtable
+
+/*
+@Item
+@csv item.csv
+*/
+type Item struct {
+	Id    int
+	Type  int
+	Price int
+}
+
+const weaponName = "Weapon"
+
+var filterNames = []string{"Armor"}
+
+func (p *Item) keySlice(i int) bool { return p.Price < 10 }
+func (p *Item) keySliceName() []string {
+	return []string{0: "Cheap"}
+}
+
+func (p *Item) valueSlice(i int) bool { return i == 0 && p.Type == 1 || i == 1 }
+func (p *Item) valueSliceName() []string {
+	names := []string{weaponName, "All" + "Item"}
+	if p == nil {
+		return []string{"Weapon", "AllItem"}
+	}
+	return (names)
+}
+
+func (p *Item) filterMap(i int) bool { return p.Type == 2 }
+func (p *Item) filterMapName() []string { return filterNames }
+`,
+		"item.csv": "Id,Type,Price\n1,1,5\n2,2,20\n3,1,30\n",
+		"table_test.go": `package gtable
+
+import (
+	"testing"
+
+	"github.com/colakuma/server-tool/table-gen/gtrt"
+)
+
+func TestCustomNames(t *testing.T) {
+	gtrt.SetDataDir(".")
+	if err := LoadAll(); err != nil {
+		t.Fatal(err)
+	}
+	if lst := GetCheap(); len(lst) != 1 || lst[0] != 1 {
+		t.Errorf("Cheap = %v", lst)
+	}
+	if lst := GetWeapon(); len(lst) != 2 {
+		t.Errorf("Weapon = %v", lst)
+	}
+	if lst := GetAllItem(); len(lst) != 3 {
+		t.Errorf("AllItem = %v", lst)
+	}
+	if m := GetArmor(); len(m) != 1 || m[2] == nil {
+		t.Errorf("Armor = %v", m)
+	}
+}
+`,
+	})
+}
+
+func TestEvalStrings(t *testing.T) {
+	t.Chdir(t.TempDir())
+	src := `package gtable
+
+import "strings"
+
+const b = "b"
+
+var names = []string{"a", b}
+
+func name() string { return "a" }
+
+func f() {
+	_ = []string{"a", b}
+	_ = names
+	_ = nil
+	_ = []string{name()}
+	_ = strings.Split("a,b", ",")
+}
+`
+	if err := os.WriteFile("c_a.go", []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	srcPkg = nil
+	t.Cleanup(func() { srcPkg = nil })
+	p := loadSource()
+
+	tests := []struct {
+		names string
+		err   string
+	}{
+		{names: "a,b"},
+		{names: "a,b"},
+		{names: ""},
+		{err: "c_a.go:15:15: name() is not a string constant"},
+		{err: `c_a.go:16:6: strings.Split("a,b", ",") is not a []string literal, constant or variable`},
+	}
+	var exprs []ast.Expr
+	ast.Inspect(p.files[0], func(n ast.Node) bool {
+		if x, ok := n.(*ast.AssignStmt); ok {
+			exprs = append(exprs, x.Rhs[0])
+		}
+		return true
+	})
+	if len(exprs) != len(tests) {
+		t.Fatalf("got %d expressions, want %d", len(exprs), len(tests))
+	}
+	for i, tt := range tests {
+		names, err := p.evalStrings(exprs[i])
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("%s: err = %v, want %q", types.ExprString(exprs[i]), err, tt.err)
+			}
+			continue
+		}
+		if err != nil || strings.Join(names, ",") != tt.names {
+			t.Errorf("%s = %v, %v, want %s", types.ExprString(exprs[i]), names, err, tt.names)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"fmt"
+	"go/types"
+	"log"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// enumSpec 枚举类型：本包中声明了常量的具名整数类型，自动识别。
+// 单元格只接受已声明的常量名或常量值，并为用到的枚举生成缺失的String()；
+// 普通整数字段可用 gtable:"enum=Type" 按枚举解析，
+// 类型只是带了上限等常量时，如 type Gold int，字段标记 gtable:"noenum" 按普通整数解析
+type enumSpec struct {
+	named  *types.Named
+	consts []*types.Const // 按声明顺序
+	short  []string       // 去掉公共前缀后的名字，用于String()
+	used   bool
+}
+
+var enums = make(map[*types.Named]*enumSpec)
+
+func (p *enumSpec) name() string {
+	return p.named.Obj().Name()
+}
+
+// markEnum 检查字段的 gtable:"enum" 和 gtable:"noenum" 标签
+func markEnum(ts *TableStruct, f *TableField) {
+	name, ok := f.attrs["enum"]
+	if ok && f.hasAttr("noenum") {
+		log.Fatalf("%s.%s: enum and noenum can not be used together", ts.typeName, f.name)
+	}
+	if !ok {
+		return
+	}
+	if f.vtype == nil {
+		log.Fatalf("%s.%s: can not resolve type %s", ts.typeName, f.name, f.typ)
+	}
+	t := f.vtype
+	if name != "" {
+		obj, _ := loadSource().pkg.Scope().Lookup(name).(*types.TypeName)
+		if obj == nil {
+			log.Fatalf("%s.%s: enum type %s not found", ts.typeName, f.name, name)
+		}
+		if b, ok := f.vtype.Underlying().(*types.Basic); !ok || b.Info()&types.IsInteger == 0 {
+			log.Fatalf("%s.%s: enum field must be an integer type", ts.typeName, f.name)
+		}
+		t = obj.Type()
+	} else if sl, ok := t.Underlying().(*types.Slice); ok {
+		t = sl.Elem()
+	}
+	if lookupEnum(t) == nil {
+		log.Fatalf("%s.%s: %s is not an integer type with declared constants", ts.typeName, f.name, t)
+	}
+}
+
+// enumOf 返回字段对应的枚举：字段类型本身是枚举，或由 gtable:"enum=ElemType" 指定，
+// 标记 gtable:"noenum" 时返回nil
+func enumOf(ts *TableStruct, f *TableField) *enumSpec {
+	if name := f.attrs["enum"]; name != "" {
+		obj, _ := loadSource().pkg.Scope().Lookup(name).(*types.TypeName)
+		return lookupEnum(obj.Type())
+	}
+	if f.vtype == nil || f.hasAttr("noenum") {
+		return nil
+	}
+	return lookupEnum(f.vtype)
+}
+
+// lookupEnum 判断t是否为本包中声明了常量的具名整数类型
+func lookupEnum(t types.Type) *enumSpec {
+	named, ok := t.(*types.Named)
+	if !ok {
+		return nil
+	}
+	if e, ok := enums[named]; ok {
+		return e
+	}
+	src := loadSource()
+	b, ok := named.Underlying().(*types.Basic)
+	if named.Obj().Pkg() != src.pkg || !ok || b.Info()&types.IsInteger == 0 {
+		return nil
+	}
+
+	e := &enumSpec{named: named}
+	scope := src.pkg.Scope()
+	for _, v := range scope.Names() {
+		if c, ok := scope.Lookup(v).(*types.Const); ok && types.Identical(c.Type(), named) {
+			e.consts = append(e.consts, c)
+		}
+	}
+	if len(e.consts) == 0 {
+		enums[named] = nil
+		return nil
+	}
+	sort.Slice(e.consts, func(i, j int) bool {
+		return e.consts[i].Pos() < e.consts[j].Pos()
+	})
+	prefix := enumPrefix(e.name(), e.consts)
+	for _, c := range e.consts {
+		e.short = append(e.short, strings.TrimPrefix(c.Name(), prefix))
+	}
+	enums[named] = e
+	return e
+}
+
+// enumPrefix 返回常量名的公共前缀，如 ElemFire/ElemIce 为 Elem，
+// 去掉前缀后必须以大写字母或数字开头，否则不去掉
+func enumPrefix(typeName string, consts []*types.Const) string {
+	names := make([]string, 0, len(consts))
+	for _, c := range consts {
+		names = append(names, c.Name())
+	}
+	valid := func(prefix string) bool {
+		for _, v := range names {
+			rest := strings.TrimPrefix(v, prefix)
+			if rest == "" || !strings.HasPrefix(v, prefix) {
+				return false
+			}
+			if c := []rune(rest)[0]; !unicode.IsUpper(c) && !unicode.IsDigit(c) {
+				return false
+			}
+		}
+		return true
+	}
+	if valid(typeName) {
+		return typeName
+	}
+	if len(names) == 1 {
+		return ""
+	}
+	prefix := names[0]
+	for _, v := range names[1:] {
+		for !strings.HasPrefix(v, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	for ; prefix != ""; prefix = prefix[:len(prefix)-1] {
+		if valid(prefix) {
+			return prefix
+		}
+	}
+	return ""
+}
+
+// lookup 按常量名、去掉前缀的名字或数值查找常量
+func (p *enumSpec) lookup(s string) (int, bool) {
+	for i, c := range p.consts {
+		if strings.EqualFold(s, c.Name()) || strings.EqualFold(s, p.short[i]) || s == c.Val().ExactString() {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// enumCellExpr 返回按枚举解析单元格的表达式
+func enumCellExpr(e *enumSpec, f *TableField, cell string, output map[string]string) string {
+	e.used = true
+	if types.Identical(f.vtype, e.named) {
+		return fmt.Sprintf("parse%s(%s)", e.name(), cell)
+	}
+	return fmt.Sprintf("gtrt.ParseEnum[%s](%s, %q, %sValues)", typeString(f, output), cell, e.name(), firstCharLower(e.name()))
+}
+
+// makeEnums 生成用到的枚举的名字表、解析函数，以及缺失的String()
+func makeEnums(output map[string]string) {
+	lst := make([]*enumSpec, 0, len(enums))
+	for _, v := range enums {
+		if v != nil && v.used {
+			lst = append(lst, v)
+		}
+	}
+	sort.Slice(lst, func(i, j int) bool {
+		return lst[i].name() < lst[j].name()
+	})
+
+	src := loadSource()
+	for _, e := range lst {
+		name := e.name()
+		values := make([]string, 0, len(e.consts)*3)
+		cases := make([]string, 0, len(e.consts))
+		seen := make(map[string]bool)
+		for i, c := range e.consts {
+			keys := []string{strings.ToLower(c.Name()), strings.ToLower(e.short[i]), c.Val().ExactString()}
+			for _, k := range keys {
+				if !seen[k] {
+					seen[k] = true
+					values = append(values, fmt.Sprintf("%q: %s,", k, c.Name()))
+				}
+			}
+			// 同值的常量只保留第一个
+			if !seen["case "+c.Val().ExactString()] {
+				seen["case "+c.Val().ExactString()] = true
+				cases = append(cases, fmt.Sprintf(enumCase, c.Name(), e.short[i]))
+			}
+		}
+		output["load"] += fmt.Sprintf(enumValues, firstCharLower(name), name, strings.Join(values, "\n\t"))
+		output["load"] += fmt.Sprintf(enumParseFunc, name, name, name, name, firstCharLower(name))
+
+		if obj, _, _ := types.LookupFieldOrMethod(e.named, true, src.pkg, "String"); obj == nil {
+			addImport(output, "strconv")
+			output["load"] += fmt.Sprintf(enumStringFunc, name, strings.Join(cases, ""), name)
+		}
+	}
+}
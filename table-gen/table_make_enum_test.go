@@ -0,0 +1,92 @@
+package main
+
+import "testing"
+
+func TestGenerateEnums(t *testing.T) {
+	generate(t, map[string]string{
+		"c_hero.go": `package gtable
+
+type Elem int
+
+const (
+	ElemFire Elem = iota + 1
+	ElemIce
+)
+
+// Kind 自带String()，不再生成
+type Kind int8
+
+const (
+	KindA Kind = 1
+	KindB Kind = 2
+)
+
+func (k Kind) String() string { return "kind" }
+
+// Gold 只带了上限常量，字段按普通整数解析
+type Gold int
+
+const MaxGold Gold = 100
+
+// Level 没有常量，不是枚举
+type Level int
+
+/*
+@Hero
+@csv hero.csv
+*/
+type Hero struct {
+	Id    int
+	Elem  Elem
+	Elems []Elem ` + "`gtable:\"sep=|\"`" + `
+	Raw   int    ` + "`gtable:\"enum=Elem\"`" + `
+	Kind  Kind
+	Gold  Gold   ` + "`gtable:\"noenum\"`" + `
+	Golds []Gold ` + "`gtable:\"sep=|,noenum\"`" + `
+	Level Level
+}
+`,
+		"hero.csv": "Id,Elem,Elems,Raw,Kind,Gold,Golds,Level\n1,fire,Ice|ElemFire|1,ice,b,250,1|300,7\n",
+		"table_test.go": `package gtable
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/colakuma/server-tool/table-gen/gtrt"
+)
+
+func TestEnums(t *testing.T) {
+	gtrt.SetDataDir(".")
+	if err := LoadAll(); err != nil {
+		t.Fatal(err)
+	}
+	p := GetHero(1)
+	if p.Elem != ElemFire || !slices.Equal(p.Elems, []Elem{ElemIce, ElemFire, ElemFire}) || p.Raw != int(ElemIce) {
+		t.Errorf("elems = %v %v %v", p.Elem, p.Elems, p.Raw)
+	}
+	if p.Kind != KindB || p.Gold != 250 || !slices.Equal(p.Golds, []Gold{1, 300}) || p.Level != 7 {
+		t.Errorf("hero = %+v", p)
+	}
+	if s := ElemIce.String() + "," + Elem(9).String() + "," + KindA.String(); s != "Ice,Elem(9),kind" {
+		t.Errorf("String() = %s", s)
+	}
+}
+
+func TestUnknownEnum(t *testing.T) {
+	dir := t.TempDir()
+	data := "Id,Elem,Elems,Raw,Kind,Gold,Golds,Level\n1,water,,,,,,\n"
+	if err := os.WriteFile(filepath.Join(dir, "hero.csv"), []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gtrt.SetDataDir(dir)
+	if err := LoadAll(); err == nil || !strings.Contains(err.Error(), "unknown Elem \"water\"") {
+		t.Fatalf("err = %v", err)
+	}
+}
+`,
+	})
+}
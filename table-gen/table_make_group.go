@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"go/types"
+	"log"
+	"strings"
+)
+
+// groupSpec 分组定义，来自 @group Field sort=-Weight,Id 或 `gtable:"group"`
+type groupSpec struct {
+	field string
+	sort  []sortField
+}
+
+// sortField 排序字段，desc为true时降序
+type sortField struct {
+	name string
+	desc bool
+}
+
+// parseSortSpec 解析排序描述，如 "-Weight,Id"，"-"表示降序
+func parseSortSpec(spec string) []sortField {
+	if spec == "" {
+		return nil
+	}
+	lst := make([]sortField, 0)
+	for _, v := range strings.Split(spec, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		sf := sortField{name: strings.TrimLeft(v, "+-")}
+		sf.desc = strings.HasPrefix(v, "-")
+		lst = append(lst, sf)
+	}
+	return lst
+}
+
+// isOrdered 判断字段类型能否用 < 比较，按底层类型判断，如 type ElemType int
+func isOrdered(f *TableField) bool {
+	if f.vtype == nil {
+		return false
+	}
+	b, ok := f.vtype.Underlying().(*types.Basic)
+	return ok && b.Info()&types.IsOrdered != 0
+}
+
+// genLess 生成比较a、b两行的语句，排序字段之后以主键升序兜底，保证结果稳定
+func genLess(ts *TableStruct, fields []sortField, a, b string) string {
+	lst := make([]sortField, 0, len(fields)+len(ts.keyField))
+	lst = append(lst, fields...)
+	for _, v := range ts.keyField {
+		lst = append(lst, sortField{name: v.name})
+	}
+
+	var sb strings.Builder
+	used := make(map[string]bool, len(lst))
+	for _, v := range lst {
+		if used[v.name] {
+			continue
+		}
+		used[v.name] = true
+		f := ts.getField(v.name)
+		if f == nil {
+			log.Fatalf("sort field %s not found in struct %s", v.name, ts.typeName)
+		}
+		if !isOrdered(f) {
+			log.Fatalf("sort field %s.%s type %s is not ordered", ts.typeName, v.name, f.typ)
+		}
+		op := "<"
+		if v.desc {
+			op = ">"
+		}
+		fmt.Fprintf(&sb, sortCmp, a, v.name, b, v.name, a, v.name, op, b, v.name)
+	}
+	sb.WriteString("return false")
+	return sb.String()
+}
+
+// makeGroups 生成 map[Field][]*T 分组及 GetXGroupByField 访问函数
+func makeGroups(ts *TableStruct, varIndex *int, output, getMap map[string]string, _make, _op, _append, _stage, _sort *[]string) {
+	for _, g := range ts.groups {
+		f := ts.getField(g.field)
+		if f == nil {
+			log.Fatalf("group field %s not found in struct %s", g.field, ts.typeName)
+		}
+		if f.vtype == nil || f.vtype == types.Typ[types.Invalid] {
+			log.Fatalf("group field %s.%s: can not resolve type %s", ts.typeName, f.name, f.typ)
+		}
+		if !types.Comparable(f.vtype) {
+			log.Fatalf("group field %s.%s: type %s is not comparable", ts.typeName, f.name, f.typ)
+		}
+		typ := typeStringOf(f.vtype, output)
+		typeName := fmt.Sprintf(groupTypeName, ts.typeName, g.field)
+		varName := fmt.Sprintf(groupVarName, ts.typeName, g.field)
+		varTmp := fmt.Sprintf("var%d", *varIndex)
+		*varIndex++
+
+		output["typePattern"] += fmt.Sprintf(groupTypePattern, typeName, typ, ts.typeName)
+		output["varPattern"] += fmt.Sprintf(groupVarPattern, varName, typeName)
+		*_make = append(*_make, fmt.Sprintf(afterMake, varTmp, typeName))
+		*_op = append(*_op, fmt.Sprintf(groupOp, varTmp, g.field, varTmp, g.field))
+		*_sort = append(*_sort, fmt.Sprintf(groupSort, varTmp, genLess(ts, g.sort, "a", "b")))
+		appendPublish(varName, varTmp, _append, _stage)
+		// 访问函数和分组类型放在同一文件，分组字段是其他包的类型时共用import
+		output["implPattern"] += fmt.Sprintf(getGroupFunc, ts.typeName, g.field, typ, ts.typeName, varName)
+		snapshotGroup(ts, g.field, f.vtype, typeName, varName, getMap)
+		addImport(output, "sort")
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSortSpec(t *testing.T) {
+	got := parseSortSpec(" -Weight, +Id ,,Name")
+	want := []sortField{{name: "Weight", desc: true}, {name: "Id"}, {name: "Name"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseSortSpec = %v, want %v", got, want)
+	}
+	if got := parseSortSpec(""); got != nil {
+		t.Errorf("parseSortSpec(\"\") = %v, want nil", got)
+	}
+}
+
+func TestGenerateGroups(t *testing.T) {
+	// 快照中的分组类型需要引入time包
+	*snapshotMode = true
+	t.Cleanup(func() { *snapshotMode = false })
+	generate(t, map[string]string{
+		"c_skill.go": `package gtable
+
+import "time"
+
+// Weight 排序字段为具名类型，按底层类型比较
+type Weight int
+
+/*
+@Skill
+@csv skill.csv
+@group Type sort=-Weight,Id
+*/
+type Skill struct {
+	Id     int
+	Type   int
+	Weight Weight
+	Day    time.Weekday ` + "`gtable:\"group\"`" + `
+}
+`,
+		"skill.csv": "Id,Type,Weight,Day\n1,1,5,1\n2,1,9,2\n3,1,5,1\n4,2,1,1\n",
+		"table_test.go": `package gtable
+
+import (
+	"testing"
+	"time"
+
+	"github.com/colakuma/server-tool/table-gen/gtrt"
+)
+
+func ids(lst []*Skill) []int {
+	r := make([]int, 0, len(lst))
+	for _, v := range lst {
+		r = append(r, v.Id)
+	}
+	return r
+}
+
+func TestGroups(t *testing.T) {
+	gtrt.SetDataDir(".")
+	if err := LoadAll(); err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(GetSkillGroupByType(1)); len(got) != 3 || got[0] != 2 || got[1] != 1 || got[2] != 3 {
+		t.Errorf("type 1 = %v, want [2 1 3]", got)
+	}
+	if got := ids(GetSkillGroupByDay(time.Monday)); len(got) != 3 {
+		t.Errorf("monday = %v", got)
+	}
+	if got := GetSkillGroupByDay(time.Sunday); got != nil {
+		t.Errorf("sunday = %v, want nil", got)
+	}
+	if got := PinSnapshot().SkillGroupByDay[time.Tuesday]; len(got) != 1 || got[0].Id != 2 {
+		t.Errorf("snapshot tuesday = %v", ids(got))
+	}
+}
+`,
+	})
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"go/types"
+	"log"
+	"strings"
+
+	"github.com/colakuma/server-tool/table-gen/gtrt"
+)
+
+// textFields 返回标记 gtable:"i18n" 的文本键字段，字段需为字符串类型
+func textFields(ts *TableStruct) []*TableField {
+	lst := make([]*TableField, 0)
+	for _, f := range columnFields(ts) {
+		if !f.hasAttr("i18n") {
+			continue
+		}
+		if b, ok := f.vtype.Underlying().(*types.Basic); !ok || b.Info()&types.IsString == 0 {
+			log.Fatalf("%s.%s: i18n field must be a string type", ts.typeName, f.name)
+		}
+		lst = append(lst, f)
+	}
+	return lst
+}
+
+// makeI18n 为文本键字段生成 p.NameText(lang) 和发布后检查每种语言都有文本的函数，
+// 返回发布后的校验函数名，没有文本键字段时返回空
+func makeI18n(ts *TableStruct, hasCheck bool, output, getMap map[string]string) string {
+	fields := textFields(ts)
+	if len(fields) == 0 {
+		return ""
+	}
+	if _, ok := tables[strings.ToLower(gtrt.LanguageTable)]; ok {
+		log.Fatalf("table %s conflicts with the built-in language table", gtrt.LanguageTable)
+	}
+	ts.depend = append(ts.depend, gtrt.LanguageTable)
+	if !strings.HasPrefix(getMap["register"], registerLanguage) {
+		getMap["register"] = registerLanguage + getMap["register"]
+	}
+	addImport(output, "golang.org/x/text/language")
+	snapshotTexts(getMap)
+
+	checks := make([]string, 0, len(fields))
+	for _, f := range fields {
+		output["implPattern"] += fmt.Sprintf(textMethod, f.name, f.name, ts.typeName, f.name, f.name)
+		checks = append(checks, fmt.Sprintf(textCheck, f.name, f.name))
+	}
+	userCheck := "nil"
+	if hasCheck {
+		userCheck = fmt.Sprintf("((*%s)(nil)).check(Staged{tx}, *m)", ts.typeName)
+	}
+	output["implPattern"] += fmt.Sprintf(textCheckFunc, ts.typeName, ts.varName, ts.typeName, ts.typeName, ts.typeName, userCheck, ts.typeName, ts.typeName, ts.typeName, ts.typeName, strings.Join(checks, ""))
+	return "check" + ts.typeName
+}
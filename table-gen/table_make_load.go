@@ -0,0 +1,286 @@
+package main
+
+import (
+	"fmt"
+	"go/types"
+	"log"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/colakuma/server-tool/table-gen/gtrt"
+)
+
+// 基础类型对应的gtrt解析函数，类型检查失败时按字段类型源码查找
+var parseFuncs = map[types.BasicKind]string{
+	types.Int: "ParseInt", types.Int8: "ParseInt", types.Int16: "ParseInt", types.Int32: "ParseInt", types.Int64: "ParseInt",
+	types.Uint: "ParseUint", types.Uint8: "ParseUint", types.Uint16: "ParseUint", types.Uint32: "ParseUint", types.Uint64: "ParseUint",
+	types.Float32: "ParseFloat", types.Float64: "ParseFloat",
+	types.Bool:   "ParseBool",
+	types.String: "ParseString",
+}
+
+// parseFuncOf 返回字段对应的gtrt解析函数，不支持的类型返回空
+func parseFuncOf(f *TableField) string {
+	if isTime(f) {
+		return "ParseTime"
+	}
+	if f.vtype != nil {
+		if b, ok := f.vtype.Underlying().(*types.Basic); ok {
+			return parseFuncs[b.Kind()]
+		}
+		if f.vtype != types.Typ[types.Invalid] {
+			return ""
+		}
+	}
+	if b, ok := types.Universe.Lookup(f.typ).(*types.TypeName); ok {
+		if basic, ok := b.Type().(*types.Basic); ok {
+			return parseFuncs[basic.Kind()]
+		}
+	}
+	return ""
+}
+
+// isTime 字段类型是否为time.Time
+func isTime(f *TableField) bool {
+	if f.vtype == nil || f.vtype == types.Typ[types.Invalid] {
+		return f.typ == "time.Time"
+	}
+	return types.TypeString(f.vtype, nil) == "time.Time"
+}
+
+// typeString 返回字段类型在生成文件中的写法，其他包的类型会登记import
+func typeString(f *TableField, output map[string]string) string {
+	if f.vtype == nil || f.vtype == types.Typ[types.Invalid] {
+		return f.typ
+	}
+	return typeStringOf(f.vtype, output)
+}
+
+func typeStringOf(t types.Type, output map[string]string) string {
+	src := loadSource()
+	return types.TypeString(t, func(p *types.Package) string {
+		if p == src.pkg {
+			return ""
+		}
+		addImport(output, p.Path())
+		return p.Name()
+	})
+}
+
+// isCollection 字段是否通过 sep、kv 或 json 标签从单元格解析集合或嵌套结构
+func isCollection(f *TableField) bool {
+	return f.hasAttr("sep") || f.hasAttr("kv") || f.hasAttr("json")
+}
+
+// elemParser 返回基础类型元素的解析函数，如 gtrt.ParseInt[int32]，noEnum时枚举元素按整数解析
+func elemParser(t types.Type, noEnum bool, output map[string]string) string {
+	if e := lookupEnum(t); e != nil && !noEnum {
+		e.used = true
+		return "parse" + e.name()
+	}
+	b, ok := t.Underlying().(*types.Basic)
+	if !ok || parseFuncs[b.Kind()] == "" {
+		return ""
+	}
+	return fmt.Sprintf("gtrt.%s[%s]", parseFuncs[b.Kind()], typeStringOf(t, output))
+}
+
+// cellExpr 返回解析单元格cell的表达式
+func cellExpr(ts *TableStruct, f *TableField, cell string, output map[string]string) string {
+	if !isCollection(f) {
+		if e := enumOf(ts, f); e != nil {
+			return enumCellExpr(e, f, cell, output)
+		}
+		if isTime(f) {
+			return fmt.Sprintf("gtrt.ParseTime(%s)", cell)
+		}
+		return fmt.Sprintf("gtrt.%s[%s](%s)", parseFuncOf(f), typeString(f, output), cell)
+	}
+	if f.vtype == nil || f.vtype == types.Typ[types.Invalid] {
+		log.Fatalf("%s.%s: can not resolve type %s", ts.typeName, f.name, f.typ)
+	}
+	typ := typeString(f, output)
+	switch {
+	case f.hasAttr("json"):
+		return fmt.Sprintf("gtrt.ParseJSON[%s](%s)", typ, cell)
+	case f.hasAttr("sep"):
+		sl, ok := f.vtype.Underlying().(*types.Slice)
+		if !ok || f.attrs["sep"] == "" {
+			log.Fatalf(`%s.%s: gtable:"sep=x" needs a slice type and a separator`, ts.typeName, f.name)
+		}
+		elem := elemParser(sl.Elem(), f.hasAttr("noenum"), output)
+		if elem == "" {
+			log.Fatalf("%s.%s: unsupported slice element %s, use gtable:\"json\"", ts.typeName, f.name, sl.Elem())
+		}
+		return fmt.Sprintf("gtrt.ParseSlice[%s](%s, %q, %s)", typ, cell, f.attrs["sep"], elem)
+	default:
+		m, ok := f.vtype.Underlying().(*types.Map)
+		seps := []rune(f.attrs["kv"])
+		if !ok || len(seps) != 2 {
+			log.Fatalf(`%s.%s: gtable:"kv=;:" needs a map type, the pair separator and the key/value separator`, ts.typeName, f.name)
+		}
+		key, value := elemParser(m.Key(), f.hasAttr("noenum"), output), elemParser(m.Elem(), f.hasAttr("noenum"), output)
+		if key == "" || value == "" {
+			log.Fatalf("%s.%s: unsupported map type %s, use gtable:\"json\"", ts.typeName, f.name, m)
+		}
+		return fmt.Sprintf("gtrt.ParseMap[%s](%s, %q, %q, %s, %s)", typ, cell, string(seps[0]), string(seps[1]), key, value)
+	}
+}
+
+// columnFields 返回从csv列加载的字段：导出、可解析且未标记 gtable:"-"
+func columnFields(ts *TableStruct) []*TableField {
+	lst := make([]*TableField, 0, len(ts.fields))
+	for _, v := range ts.fields {
+		if !isExported(v.name) || v.hasAttr("-") || parseFuncOf(v) == "" && !isCollection(v) {
+			continue
+		}
+		lst = append(lst, v)
+	}
+	return lst
+}
+
+// column 返回字段对应的列名，表头与字段名不一致时用 gtable:"col=列名" 指定
+func (p *TableField) column() string {
+	if col := p.attrs["col"]; col != "" {
+		return col
+	}
+	return p.name
+}
+
+func isExported(name string) bool {
+	return name != "" && strings.ToUpper(name[:1]) == name[:1]
+}
+
+// keyExpr 返回由行数据p计算主键的表达式
+func keyExpr(ts *TableStruct) string {
+	if len(ts.keyField) == 1 {
+		return "p." + ts.keyField[0].name
+	}
+	_, _, getKey, allIntKey := ts.GenKeyParams()
+	if ts.useKeyStruct(allIntKey) {
+		return fmt.Sprintf("%s{%s}", ts.mapKeyType, getKey)
+	}
+	return fmt.Sprintf("gtrt.MakeKey%d(%s)", len(ts.keyField), getKey)
+}
+
+// resolveDefault 在生成时检查默认值能否解析，返回用于文档的写法，枚举附带数值
+func resolveDefault(ts *TableStruct, f *TableField) string {
+	def := f.attrs["default"]
+	fail := func(err error) {
+		log.Fatalf("%s.%s: bad default %q: %v", ts.typeName, f.name, def, err)
+	}
+	if isCollection(f) {
+		return def
+	}
+	if e := enumOf(ts, f); e != nil {
+		if i, ok := e.lookup(def); ok {
+			return fmt.Sprintf("%s(%s)", e.short[i], e.consts[i].Val().ExactString())
+		}
+		fail(fmt.Errorf("unknown %s", e.name()))
+	}
+	var err error
+	switch parseFuncOf(f) {
+	case "ParseInt":
+		_, err = strconv.ParseInt(def, 10, 64)
+	case "ParseUint":
+		_, err = strconv.ParseUint(def, 10, 64)
+	case "ParseFloat":
+		_, err = strconv.ParseFloat(def, 64)
+	case "ParseBool":
+		_, err = strconv.ParseBool(def)
+	case "ParseString":
+		return strconv.Quote(def)
+	case "ParseTime":
+		_, err = gtrt.ParseTime(def)
+	}
+	if err != nil {
+		fail(err)
+	}
+	return def
+}
+
+// makeLoadOne 生成列绑定、逐行解析和主键函数，不依赖反射
+func makeLoadOne(ts *TableStruct, output map[string]string) {
+	fields := columnFields(ts)
+	for _, v := range ts.keyField {
+		if !slices.Contains(fields, v) {
+			log.Fatalf("key field %s.%s can not be loaded from csv", ts.typeName, v.name)
+		}
+	}
+
+	colType := strings.ToLower(ts.typeName[:1]) + ts.typeName[1:]
+	decl := make([]string, 0, len(fields))
+	names := make([]string, 0, len(fields))
+	init := make([]string, 0, len(fields))
+	cells := make([]string, 0, len(fields))
+	keyCells := make([]string, 0, len(ts.keyField))
+	var required, defaults, needed, keyNames []string
+	for i, v := range fields {
+		decl = append(decl, v.name+" int")
+		names = append(names, fmt.Sprintf("%q", v.column()))
+		init = append(init, fmt.Sprintf("%s: idx[%d]", v.name, i))
+		isKey := slices.Contains(ts.keyField, v)
+		if isKey {
+			keyNames = append(keyNames, fmt.Sprintf("%q", v.column()))
+		}
+		switch def, hasDef := v.attrs["default"]; {
+		case hasDef && v.hasAttr("required"):
+			log.Fatalf("%s.%s: default and required can not be used together", ts.typeName, v.name)
+		case hasDef:
+			defaults = append(defaults, v.name+"="+resolveDefault(ts, v))
+			expr := cellExpr(ts, v, fmt.Sprintf("gtrt.CellOr(row, c.%s, %q)", v.name, def), output)
+			cells = append(cells, fmt.Sprintf(parseCellMerge, v.name, v.name, expr, v.column()))
+			if isKey {
+				keyCells = append(keyCells, fmt.Sprintf(parseCellDefault, v.name, expr, v.column()))
+			}
+			continue
+		case v.hasAttr("required"):
+			required = append(required, v.name)
+			cells = append(cells, fmt.Sprintf(requireCell, v.name, v.name, v.column()))
+		}
+		needed = append(needed, fmt.Sprintf("%q", v.column()))
+		cell := fmt.Sprintf(parseCell, v.name, v.name, cellExpr(ts, v, "row[c."+v.name+"]", output), v.column())
+		cells = append(cells, cell)
+		if isKey {
+			keyCells = append(keyCells, cell)
+		}
+	}
+
+	doc := ""
+	if len(required) > 0 || len(defaults) > 0 {
+		doc = fmt.Sprintf("// parse%sRow 解析一行数据\n//\n", ts.typeName)
+		if len(required) > 0 {
+			doc += "// 必填: " + strings.Join(required, ", ") + "\n"
+		}
+		if len(defaults) > 0 {
+			doc += "// 默认值: " + strings.Join(defaults, ", ") + "\n"
+		}
+	}
+
+	missing := "nil"
+	if len(needed) > 0 {
+		missing = fmt.Sprintf("gtrt.MissingColumns(header, %s)", strings.Join(needed, ", "))
+	}
+	output["load"] += fmt.Sprintf(columnsType, colType, strings.Join(decl, "\n\t"), ts.typeName, ts.typeName, ts.typeName, strings.Join(names, ", "), colType, strings.Join(init, ", "), ts.typeName, ts.typeName, ts.typeName, missing, strings.Join(keyNames, ", "))
+	output["load"] += fmt.Sprintf(parseRowFunc, doc, colType, ts.typeName, ts.typeName, ts.typeName, ts.typeName, strings.Join(cells, ""), ts.typeName, colType, ts.typeName, ts.typeName, ts.typeName, strings.Join(keyCells, ""))
+	makeValidateOne(ts, output)
+	output["load"] += fmt.Sprintf(keyOfFunc, ts.typeName, ts.typeName, ts.mapKeyType, keyExpr(ts))
+}
+
+func makeLoad(lst []*TableStruct) {
+	output := make(map[string]string)
+	for _, v := range lst {
+		makeLoadOne(v, output)
+	}
+	makeEnums(output)
+
+	WriteLoadGo(output, "./table_load.go")
+}
+
+func WriteLoadGo(output map[string]string, filePath string) {
+	context := fmt.Sprintf(loadFile, output["imports"], *rtPkg, output["load"])
+
+	os.WriteFile(filePath, StringBytes(context), 0o600|0o064)
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"go/types"
+	"os"
+)
+
+// makeNotifyOne 生成 OnXReloaded 订阅和按字段比较的 diffXRow
+func makeNotifyOne(ts *TableStruct, output map[string]string) {
+	cmps := ""
+	for _, v := range columnFields(ts) {
+		// time.Time的==会比较时区指针和单调时钟，同一时刻也可能不相等
+		if isTime(v) {
+			cmps += fmt.Sprintf(diffFieldTime, v.name, v.name, v.name)
+		} else if v.vtype == nil || types.Comparable(v.vtype) {
+			cmps += fmt.Sprintf(diffField, v.name, v.name, v.name)
+		} else {
+			cmps += fmt.Sprintf(diffFieldDeep, v.name, v.name, v.name)
+			addImport(output, "reflect")
+		}
+	}
+
+	n, k := ts.typeName, ts.mapKeyType
+	output["notify"] += fmt.Sprintf(notifyFunc, n, k, n, n, k, n, n, n, n, n, n, n, n, n, n, n, n, n, n, cmps)
+}
+
+func makeNotify(lst []*TableStruct) {
+	output := make(map[string]string)
+	for _, v := range lst {
+		makeNotifyOne(v, output)
+	}
+
+	WriteNotifyGo(output, "./table_notify.go")
+}
+
+func WriteNotifyGo(output map[string]string, filePath string) {
+	context := fmt.Sprintf(notifyFile, output["imports"], *rtPkg, output["notify"])
+
+	os.WriteFile(filePath, StringBytes(context), 0o600|0o064)
+}
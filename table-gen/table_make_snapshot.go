@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"go/types"
+	"os"
+	"strings"
+)
+
+// 快照模式下所有主表和自定义集合汇总到一个 Snapshot，
+// 每次发布完成后整体替换，持有同一快照即可读到一致的跨表数据
+
+func snapshotMap(ts *TableStruct, params, key string, output map[string]string) {
+	if !*snapshotMode {
+		return
+	}
+	name := ts.typeName + "Map"
+	output["snapField"] += fmt.Sprintf(snapField, name, "*"+name)
+	output["snapLoad"] += fmt.Sprintf(snapLoadMap, name, ts.varName)
+	output["snapFunc"] += fmt.Sprintf(snapGetFunc, ts.typeName, params, ts.typeName, key, name)
+	output["snapFunc"] += fmt.Sprintf(snapGetAllFunc, name, "*"+name, name)
+}
+
+func snapshotCustom(name, typeName, varName string, output map[string]string) {
+	if !*snapshotMode {
+		return
+	}
+	output["snapField"] += fmt.Sprintf(snapField, name, typeName)
+	output["snapLoad"] += fmt.Sprintf(snapLoadCustom, varName, name)
+	output["snapFunc"] += fmt.Sprintf(snapGetAllFunc, name, typeName, name)
+}
+
+func snapshotGroup(ts *TableStruct, field string, t types.Type, typeName, varName string, output map[string]string) {
+	if !*snapshotMode {
+		return
+	}
+	src := loadSource()
+	typ := types.TypeString(t, func(p *types.Package) string {
+		if p == src.pkg {
+			return ""
+		}
+		addSnapImport(output, p.Path())
+		return p.Name()
+	})
+	output["snapField"] += fmt.Sprintf(snapField, typeName, typeName)
+	output["snapLoad"] += fmt.Sprintf(snapLoadCustom, varName, typeName)
+	output["snapFunc"] += fmt.Sprintf(snapGroupFunc, ts.typeName, field, typ, ts.typeName, typeName)
+}
+
+// snapshotTexts 有文本键字段时快照同时持有语言表，快照内的文本与表数据一致
+func snapshotTexts(output map[string]string) {
+	if !*snapshotMode || strings.Contains(output["snapField"], snapTextField) {
+		return
+	}
+	output["snapField"] += snapTextField
+	output["snapLoad"] += snapLoadTexts
+	output["snapFunc"] += snapTextFunc
+	addSnapImport(output, "golang.org/x/text/language")
+}
+
+func addSnapImport(output map[string]string, pkg string) {
+	line := fmt.Sprintf("\t%q\n", pkg)
+	if !strings.Contains(output["snapImports"], line) {
+		output["snapImports"] += line
+	}
+}
+
+func WriteSnapshotGo(output map[string]string, filePath string) {
+	context := fmt.Sprintf(snapshotFile, output["snapImports"], *rtPkg, output["snapField"], output["snapLoad"], output["snapFunc"])
+
+	os.WriteFile(filePath, StringBytes(context), 0o600|0o064)
+}
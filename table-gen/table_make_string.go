@@ -1,33 +1,54 @@
 package main
 
 const (
-	varName  = "map%s"
-	mapType  = "%sMap map[%s]*%s\n\t"
-	mapVar   = "%s atomic.Pointer[%sMap]\n\t"
-	callLoad = "\tLoad%s()\n"
-	keyMake  = `key := fmt.Sprintf("%s",%s)
+	varName       = "map%s"
+	mapType       = "%sMap map[%s]*%s\n\t"
+	mapVar        = "%s gtrt.Var[%sMap]\n\t"
+	keyMakeStruct = `key := %s{%s}
 	`
-	keyMake2 = `key := tools.MakeKey%d(%s)
+	keyMake2 = `key := gtrt.MakeKey%d(%s)
 	`
 	loadFunc = `
-func Load%s() {
+// layers%s 最近一次加载%s时覆盖层的操作
+var layers%s []gtrt.LayerOp
+
+// load%s 由Reload调用，新数据暂存在tx中，全部表校验通过后才发布
+func load%s(tx *gtrt.Tx) error {
 	tmp := make(%sMap)
-	if !hot {
-		initHotLoad("%s", Load%s)
-		initHotLoad("%s", Load%s)
+	ts, err := gtrt.ReadTables(%s)
+	if err != nil {
+		return err
+	}
+	layers, err := gtrt.LoadTables(ts, tmp, bind%sColumns, keyOf%s)
+	if err != nil {
+		return err
 	}
-	load("%s", "%s", &tmp)
-	AfterLoad%s(&tmp)
-}	
+	layers%s = layers
+	return afterLoad%s(tx, &tmp)
+}
 `
 	loadAllFunc = `
-func LoadAll() {
-	hot = false
-%s
-	hot = true
-	callAfterLoad(afterLoadCall)
-	afterLoadCall = afterLoadCall[:0]
-}	
+func LoadAll() error {
+	return gtrt.LoadAll()
+}
+
+// ReloadTables 手动重新加载指定表及依赖它们的表，供GM命令使用
+func ReloadTables(names ...string) error {
+	return gtrt.Reload(names...)
+}
+
+// Staged 加载中的表数据，校验通过它读取依赖表本次加载的数据，没有重新加载的表读到当前数据
+type Staged struct {
+	tx *gtrt.Tx
+}
+`
+	registerTable = `	gtrt.RegisterTable(gtrt.TableInfo{
+		Name:   "%s",
+		Files:  %s,
+		Depend: %s,
+		Load:   load%s,
+		Check:  %s,
+	})
 `
 	getFunc = `
 func Get%s(%s) *%s {
@@ -38,6 +59,15 @@ func Get%s(%s) *%s {
 func Get%sMap() *%sMap {
 	return %s.Load()
 }
+`
+	stagedGetFunc = `
+func (s Staged) Get%s(%s) *%s {
+	%sreturn (*%s.In(s.tx))[key]
+}
+
+func (s Staged) Get%sMap() *%sMap {
+	return %s.In(s.tx)
+}
 `
 
 	fileContext = `// Code generated by table-gen. DO NOT EDIT.
@@ -45,17 +75,24 @@ func Get%sMap() *%sMap {
 package gtable
 
 import (
-	"sync/atomic"
+	gtrt "%s"
+)
 
-	"%s/tools"
+// 与运行时版本不一致时编译失败，需同时升级table-gen和gtrt
+const (
+	_ = gtrt.EnforceVersion(%d - gtrt.Version)
+	_ = gtrt.EnforceVersion(gtrt.Version - %d)
 )
 
 type(
 	%s)
 
 var(
-	hot bool
 	%s)
+
+func init() {
+%s}
+%s
 %s
 %s
 %s
@@ -85,6 +122,8 @@ package gtable
 
 import (
 	"sync/atomic"
+	%s
+	gtrt "%s"
 )
 
 type(
@@ -100,22 +139,68 @@ var(
 )
 
 const (
-	afterFunc = `func AfterLoad%s(m *%sMap) {%s
+	afterFunc = `func afterLoad%s(tx *gtrt.Tx, m *%sMap) error {%s
+	%s.Stage(tx, m)%s
 %s
-	for %s, v := range *m {
-%s}
-	%s
-	appendAfterLoad(func() {
-		%s.Store(m)
+%s	%s
+%s
+	tx.AppendAfterLoad(func() {
+		old := %s.Swap(m)
+		tx.AfterCommit(func() {
+			notify%sReloaded(old, m)
+		})
 %s
 	})
+	return nil
 }
 `
-	afterFunc2 = `func AfterLoad%s(m *%sMap) {%s
-	appendAfterLoad(func() {
-		%s.Store(m)
+	afterFunc2 = `func afterLoad%s(tx *gtrt.Tx, m *%sMap) error {%s
+	%s.Stage(tx, m)%s
+	tx.AppendAfterLoad(func() {
+		old := %s.Swap(m)
+		tx.AfterCommit(func() {
+			notify%sReloaded(old, m)
+		})
 	})
+	return nil
 }
+`
+	checkFunc = `
+func check%s(tx *gtrt.Tx) error {
+	if m := %s.In(tx); m != nil {
+		return ((*%s)(nil)).check(Staged{tx}, *m)
+	}
+	return nil
+}
+`
+	registerLanguage = "\tgtrt.RegisterLanguageTable()\n"
+	textMethod       = `
+// %sText 返回%s在当前语言表中lang下的文本，持有快照时用 Snapshot.Text 读取
+func (p *%s) %sText(lang language.Tag) string {
+	return gtrt.Text(p.%s, lang)
+}
+`
+	textCheckFunc = `
+func check%s(tx *gtrt.Tx) error {
+	m := %s.In(tx)
+	if m == nil {
+		return nil
+	}
+	if err := gtrt.Validate("%s", *m, func(p *%s) []error { return checkText%s(tx, p) }); err != nil {
+		return err
+	}
+	return %s
+}
+
+// checkText%s 检查%s的文本键在每种语言中都有文本
+func checkText%s(tx *gtrt.Tx, p *%s) []error {
+	var errs []error
+%s	return errs
+}
+`
+	textCheck = `	if err := gtrt.CheckText(tx, "%s", p.%s); err != nil {
+		errs = append(errs, err)
+	}
 `
 	getCustomFunc = `func Get%s() %s {
 	if slice := %s.Load(); slice != nil {
@@ -125,28 +210,142 @@ const (
 }
 
 `
+	validateCall = `
+	if err := gtrt.Validate("%s", *m, validate%s); err != nil {
+		return err
+	}`
 	structAfterLoad = `
 	((*%s)(nil)).afterLoad(*m)`
 	afterMake = "\t%s := make(%s, 0)"
-	afterOp   = `		if v.%s(%d) {
+	afterLoop = "\tfor %s, v := range *m {\n%s}\n"
+	afterCond = "v.%s(%d)"
+	afterOp   = `		if %s {
 			%s = append(%s, %s)
 		}
 	`
 	afterSort  = "((*%s)(nil)).%sSort(%s,%d)"
-	afterOpMap = `		if v.%s(%d) {
+	afterOpMap = `		if %s {
 			%s[k]=v
 		}
 `
+	afterStage  = "\t%s.Stage(tx, &%s)"
 	afterAppend = "\t\t%s.Store(&%s)"
 )
 
 const (
-	getKeyStringPattern = `func (p *%s) GetKey() any {
-	return fmt.Sprintf("%s", %s)
+	groupTypeName    = "%sGroupBy%s"
+	groupVarName     = "group%sBy%s"
+	groupTypePattern = "%s\tmap[%s][]*%s\n\t"
+	groupVarPattern  = "%s\tgtrt.Var[%s]\n\t"
+	groupOp          = "\t\t%s[v.%s] = append(%s[v.%s], v)\n"
+	groupSort        = `for _, g := range %s {
+		sort.Slice(g, func(i, j int) bool {
+			a, b := g[i], g[j]
+			%s
+		})
+	}`
+	sliceSort = `sort.SliceStable(%s, func(i, j int) bool {
+		a, b := %s, %s
+		%s
+	})`
+	sortCmp = `if %s.%s != %s.%s {
+		return %s.%s %s %s.%s
+	}
+	`
+	windowTypeName    = "%sWindow"
+	windowVarName     = "window%s"
+	windowTypePattern = "%s\t= gtrt.WindowIndex[%s]\n\t"
+	windowMake        = `	%s, err := gtrt.NewWindowIndex("%s", *m, windowOf%s)
+	if err != nil {
+		return err
+	}`
+	windowFuncs = `
+func windowOf%s(p *%s) gtrt.Window {
+	return gtrt.Window{Start: %s, End: %s}
+}
+
+// GetActive%s 返回now时处于有效时间段内的行，按主键排序，结果不可修改
+func GetActive%s(now time.Time) []*%s {
+	if w := %s.Load(); w != nil {
+		return w.Active(now)
+	}
+	return nil
+}
+
+// Get%sActiveAt 返回主键对应且now时有效的行
+func Get%sActiveAt(%s, now time.Time) *%s {
+	if v := Get%s(%s); v != nil && windowOf%s(v).Contains(now) {
+		return v
+	}
+	return nil
+}
+
+// Get%sNextTransition 返回now之后最近一次有行开始或结束的时间，没有时为零值
+func Get%sNextTransition(now time.Time) time.Time {
+	if w := %s.Load(); w != nil {
+		return w.Next(now)
+	}
+	return time.Time{}
+}
+`
+	weightTypeName         = "%sWeighted"
+	weightVarName          = "weighted%s"
+	weightTypePattern      = "%s\t= gtrt.Weighted[%s]\n\t"
+	weightGroupTypeName    = "%sWeightedBy%s"
+	weightGroupVarName     = "weighted%sBy%s"
+	weightGroupTypePattern = "%s\t= map[%s]gtrt.Weighted[%s]\n\t"
+	weightMake             = `	%s, err := gtrt.NewWeighted("%s", *m, weightOf%s)
+	if err != nil {
+		return err
+	}`
+	weightGroupMake = `	%s, err := gtrt.NewWeightedGroups("%s", *m, func(p *%s) %s { return p.%s }, weightOf%s)
+	if err != nil {
+		return err
+	}`
+	weightFuncs = `
+func weightOf%s(p *%s) int {
+	return int(p.%s)
+}
+
+// Rand%s 按%s权重随机一行，rng为nil时使用全局随机源，没有可选的行时返回nil
+func Rand%s(rng gtrt.Rand) *%s {
+	if w := %s.Load(); w != nil {
+		return w.Pick(rng)
+	}
+	return nil
+}
+`
+	weightGroupFunc = `
+// Rand%sBy%s 在%s为g的行中按%s权重随机一行
+func Rand%sBy%s(g %s, rng gtrt.Rand) *%s {
+	if m := %s.Load(); m != nil {
+		if w, ok := (*m)[g]; ok {
+			return w.Pick(rng)
+		}
+	}
+	return nil
+}
+`
+	getGroupFunc = `func Get%sGroupBy%s(v %s) []*%s {
+	if m := %s.Load(); m != nil {
+		return (*m)[v]
+	}
+	return nil
+}
+
+`
+)
+
+const (
+	keyStructName = "%sKey"
+	keyStructType = "%s struct {\n\t%s\n\t}\n\t"
+
+	getKeyStructPattern = `func (p *%s) GetKey() any {
+	return %s{%s}
 }
 `
 	getKeyNPattern = `func (p *%s) GetKey() any {
-	return tools.MakeKey%d(%s)
+	return gtrt.MakeKey%d(%s)
 }
 `
 	getKeyPattern = `func (p *%s) GetKey() any {
@@ -154,3 +353,303 @@ const (
 }
 `
 )
+
+const (
+	loadFile = `// Code generated by table-gen. DO NOT EDIT.
+
+package gtable
+
+import (
+	%s
+	gtrt "%s"
+)
+%s
+`
+	columnsType = `
+type %sColumns struct {
+	%s
+}
+
+// bind%sColumns 按表头绑定列，有默认值的列可以缺少，覆盖文件只要求有主键列
+func bind%sColumns(header []string) gtrt.Columns[%s] {
+	idx := gtrt.BindColumns(header, %s)
+	c := &%sColumns{%s}
+	return gtrt.Columns[%s]{
+		Parse:      c.parse%sRow,
+		Key:        c.parse%sKey,
+		Missing:    %s,
+		MissingKey: gtrt.MissingColumns(header, %s),
+	}
+}
+`
+	parseRowFunc = `
+%sfunc (c *%sColumns) parse%sRow(row []string, base *%s) (*%s, error) {
+	p := &%s{}
+	if base != nil {
+		*p = *base
+	}
+	var err error
+%s
+	return p, nil
+}
+
+// parse%sKey 只解析主键列
+func (c *%sColumns) parse%sKey(row []string) (*%s, error) {
+	p := &%s{}
+	var err error
+%s
+	return p, nil
+}
+`
+	parseCell = `	if c.%s >= 0 {
+		if p.%s, err = %s; err != nil {
+			return nil, &gtrt.CellError{Column: "%s", Err: err}
+		}
+	}
+`
+	enumValues = `
+var %sValues = map[string]%s{
+	%s
+}
+`
+	enumParseFunc = `
+func parse%s(s string) (%s, error) {
+	return gtrt.ParseEnum[%s](s, %q, %sValues)
+}
+`
+	enumStringFunc = `
+func (v %s) String() string {
+	switch v {
+%s	}
+	return "%s(" + strconv.FormatInt(int64(v), 10) + ")"
+}
+`
+	enumCase = `	case %s:
+		return %q
+`
+	parseCellDefault = `	if p.%s, err = %s; err != nil {
+		return nil, &gtrt.CellError{Column: "%s", Err: err}
+	}
+`
+	parseCellMerge = `	if c.%s >= 0 || base == nil {
+		if p.%s, err = %s; err != nil {
+			return nil, &gtrt.CellError{Column: "%s", Err: err}
+		}
+	}
+`
+	requireCell = `	if c.%s >= 0 && gtrt.CellOr(row, c.%s, "") == "" {
+		return nil, &gtrt.CellError{Column: "%s", Err: gtrt.ErrRequired}
+	}
+`
+	validateFunc = `
+func validate%s(p *%s) []error {
+	var errs []error
+%s	return errs
+}
+`
+	validateCheck = `	if %s {
+		errs = append(errs, fmt.Errorf(%q, p.%s))
+	}
+`
+	validateRegexp = `
+var %s = regexp.MustCompile(%q)
+`
+	keyOfFunc = `
+func keyOf%s(p *%s) %s {
+	return %s
+}
+`
+)
+
+const (
+	snapshotFile = `// Code generated by table-gen. DO NOT EDIT.
+
+package gtable
+
+import (
+	"sync/atomic"
+%s
+	gtrt "%s"
+)
+
+// Snapshot 所有表数据的一致快照，热加载全部发布后整体替换
+type Snapshot struct {
+	%s
+}
+
+var snapshot atomic.Pointer[Snapshot]
+
+func init() {
+	gtrt.OnCommit(publishSnapshot)
+}
+
+func publishSnapshot() {
+	s := &Snapshot{}
+	%s
+	snapshot.Store(s)
+}
+
+// PinSnapshot 返回当前快照，请求处理期间持有同一快照可避免读到新旧混合的数据
+func PinSnapshot() *Snapshot {
+	return snapshot.Load()
+}
+%s
+`
+	snapField      = "%s %s\n\t"
+	snapLoadMap    = "s.%s = %s.Load()\n\t"
+	snapLoadCustom = `if v := %s.Load(); v != nil {
+		s.%s = *v
+	}
+	`
+	snapGetFunc = `
+func (s *Snapshot) Get%s(%s) *%s {
+	%sreturn (*s.%s)[key]
+}
+`
+	snapGetAllFunc = `
+func (s *Snapshot) Get%s() %s {
+	return s.%s
+}
+`
+	snapWindowFunc = `
+func (s *Snapshot) GetActive%s(now time.Time) []*%s {
+	return s.%s.Active(now)
+}
+
+func (s *Snapshot) Get%sActiveAt(%s, now time.Time) *%s {
+	if v := s.Get%s(%s); v != nil && windowOf%s(v).Contains(now) {
+		return v
+	}
+	return nil
+}
+
+func (s *Snapshot) Get%sNextTransition(now time.Time) time.Time {
+	return s.%s.Next(now)
+}
+`
+	snapWeightFunc = `
+func (s *Snapshot) Rand%s(rng gtrt.Rand) *%s {
+	return s.%s.Pick(rng)
+}
+`
+	snapWeightGroupFunc = `
+func (s *Snapshot) Rand%sBy%s(g %s, rng gtrt.Rand) *%s {
+	w := s.%s[g]
+	return w.Pick(rng)
+}
+`
+	snapTextField = "texts *gtrt.TextTable\n\t"
+	snapLoadTexts = "s.texts = gtrt.Texts()\n\t"
+	snapTextFunc  = `
+// Text 返回文本键在lang下的文本，读取的是快照发布时的语言表
+func (s *Snapshot) Text(key string, lang language.Tag) string {
+	return s.texts.Text(key, lang)
+}
+`
+	snapGroupFunc = `
+func (s *Snapshot) Get%sGroupBy%s(v %s) []*%s {
+	return s.%s[v]
+}
+`
+)
+
+const (
+	notifyFile = `// Code generated by table-gen. DO NOT EDIT.
+
+package gtable
+
+import (
+	%s
+	gtrt "%s"
+)
+%s
+`
+	notifyFunc = `
+type %sDiff = gtrt.Diff[%s]
+
+var reloaded%s gtrt.Notifier[*%sMap, %s]
+
+// On%sReloaded 订阅%s热加载，diff按主键比较新旧数据
+func On%sReloaded(fn func(old, new *%sMap, diff %sDiff)) {
+	reloaded%s.Subscribe(fn)
+}
+
+func notify%sReloaded(old, new *%sMap) {
+	if old == nil {
+		return
+	}
+	reloaded%s.Notify(old, new, func() %sDiff {
+		d := gtrt.DiffMaps(*old, *new, diff%sRow)
+		d.Layers = layers%s
+		return d
+	})
+}
+
+func diff%sRow(a, b *%s) []string {
+	var fields []string
+%s
+	return fields
+}
+`
+	diffField = `	if a.%s != b.%s {
+		fields = append(fields, "%s")
+	}
+`
+	diffFieldTime = `	if !a.%s.Equal(b.%s) {
+		fields = append(fields, "%s")
+	}
+`
+	diffFieldDeep = `	if !reflect.DeepEqual(a.%s, b.%s) {
+		fields = append(fields, "%s")
+	}
+`
+)
+
+const (
+	lintFile = `// Code generated by table-gen. DO NOT EDIT.
+
+package gtable
+
+import (
+	"os"
+	"testing"
+	%s
+	gtrt "%s"
+)
+
+// TestTableGenLint 由 table-gen lint 生成并运行，结束后删除
+func TestTableGenLint(t *testing.T) {
+	out := os.Getenv("TABLEGEN_LINT_OUT")
+	if out == "" {
+		t.Skip("run by table-gen lint")
+	}
+	gtrt.SetDataDir(os.Getenv("TABLEGEN_LINT_DATA"))
+	if v := os.Getenv("TABLEGEN_LINT_LAYERS"); v != "" {
+		gtrt.SetLayers(strings.Split(v, ",")...)
+	}
+	if v := os.Getenv("TABLEGEN_LINT_LANG"); v != "" {
+		if err := gtrt.SetLanguages(v, strings.Split(os.Getenv("TABLEGEN_LINT_LOCALES"), ",")...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r := &gtrt.LintReport{}
+	if r.Load(LoadAll) {
+		%s
+	}
+	if err := r.WriteFile(out); err != nil {
+		t.Fatal(err)
+	}
+}
+`
+	lintRefs     = `gtrt.LintRefs(r, "%s", "%s", *Get%sMap(), func(p *%s) []%s { return %s }, "%s", *Get%sMap())`
+	lintRefSlice = `func() []%s {
+			lst := make([]%s, 0, len(p.%s))
+			for _, v := range p.%s {
+				lst = append(lst, %s(v))
+			}
+			return lst
+		}()`
+	lintUnique  = `gtrt.LintUnique(r, "%s", "%s", *Get%sMap(), func(p *%s) %s { return p.%s })`
+	lintOrphans = `gtrt.LintOrphans(r, "%s", *Get%sMap())`
+	lintFunc    = `gtrt.LintFunc(r, "%s", %s()%s)`
+)
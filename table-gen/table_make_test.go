@@ -0,0 +1,150 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/types"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseFieldTag(t *testing.T) {
+	tests := []struct {
+		tag  string
+		want map[string]string
+	}{
+		{"", map[string]string{}},
+		{`json:"id"`, map[string]string{}},
+		{`gtable:"key,group"`, map[string]string{"key": "", "group": ""}},
+		{`gtable:"oneof='a,b',min=1"`, map[string]string{"oneof": "a,b", "min": "1"}},
+		{`gtable:"required,regex=^[a-z,]+$"`, map[string]string{"required": "", "regex": "^[a-z,]+$"}},
+		{`gtable:"regex='^a,b$',max=3"`, map[string]string{"regex": "^a,b$", "max": "3"}},
+		{`gtable:"len<=5,len>0"`, map[string]string{"len<": "5", "len>": "1"}},
+		{`gtable:"len<5,len>=2"`, map[string]string{"len<": "4", "len>": "2"}},
+		{`gtable:"enum,weight=Rate"`, map[string]string{"enum": "", "weight": "Rate"}},
+		{`gtable:"enum=Elem,noenum"`, map[string]string{"enum": "Elem", "noenum": ""}},
+	}
+	for _, tt := range tests {
+		src := "struct {\n\tF int"
+		if tt.tag != "" {
+			src += " `" + tt.tag + "`"
+		}
+		expr, err := parser.ParseExpr(src + "\n}")
+		if err != nil {
+			t.Fatalf("%s: %v", tt.tag, err)
+		}
+		field := expr.(*ast.StructType).Fields.List[0]
+		if got := parseFieldTag("T", field); !maps.Equal(got, tt.want) {
+			t.Errorf("parseFieldTag(%s) = %v, want %v", tt.tag, got, tt.want)
+		}
+	}
+}
+
+// generate 在testdata下的临时目录中写入files，运行生成器并编译生成的包，
+// 再运行files中的测试，用于检查生成的代码在真实数据上的行为
+func generate(t *testing.T, files map[string]string) {
+	t.Helper()
+	if err := os.MkdirAll("testdata", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	dir, err := os.MkdirTemp("testdata", "gen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, _ = filepath.Abs(dir)
+	t.Cleanup(func() {
+		os.RemoveAll(dir)
+		os.Remove(filepath.Dir(dir)) // 为空时删除testdata
+	})
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	srcPkg, fatal = nil, false
+	tables = make(map[string]*TableStruct)
+	enums = make(map[*types.Named]*enumSpec)
+	t.Chdir(dir)
+	if err := step3(); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command("go", "test", ".")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("generated package: %v\n%s", err, out)
+	}
+}
+
+func TestGenerateKeys(t *testing.T) {
+	generate(t, map[string]string{
+		"c_offer.go": `package gtable
+
+/*
+@Offer
+@csv offer.csv
+*/
+type Offer struct {
+	Shop  int    ` + "`gtable:\"key\"`" + `
+	Code  string ` + "`gtable:\"key\"`" + `
+	Price int
+}
+
+/*
+@Cell
+@csv cell.csv
+*/
+type Cell struct {
+	X    int ` + "`gtable:\"key\"`" + `
+	Y    int ` + "`gtable:\"key\"`" + `
+	Cost int
+}
+
+/*
+@Quad
+@csv quad.csv
+*/
+type Quad struct {
+	A int ` + "`gtable:\"key\"`" + `
+	B int ` + "`gtable:\"key\"`" + `
+	C int ` + "`gtable:\"key\"`" + `
+	D int ` + "`gtable:\"key\"`" + `
+}
+`,
+		"offer.csv": "Shop,Code,Price\n1,a,10\n1,b,20\n2,a,30\n",
+		"cell.csv":  "X,Y,Cost\n1,2,5\n2,1,6\n",
+		"quad.csv":  "A,B,C,D\n1,2,3,4\n1,2,3,5\n",
+		"table_test.go": `package gtable
+
+import (
+	"testing"
+
+	"github.com/colakuma/server-tool/table-gen/gtrt"
+)
+
+func TestKeys(t *testing.T) {
+	gtrt.SetDataDir(".")
+	if err := LoadAll(); err != nil {
+		t.Fatal(err)
+	}
+	if p := GetOffer(1, "b"); p == nil || p.Price != 20 || p.GetKey() != (OfferKey{Shop: 1, Code: "b"}) {
+		t.Errorf("GetOffer(1, b) = %v", p)
+	}
+	if p := GetOffer(2, "b"); p != nil {
+		t.Errorf("GetOffer(2, b) = %v, want nil", p)
+	}
+	if p := GetCell(2, 1); p == nil || p.Cost != 6 || p.GetKey() != gtrt.MakeKey2(2, 1) {
+		t.Errorf("GetCell(2, 1) = %v", p)
+	}
+	if p := GetQuad(1, 2, 3, 5); p == nil || p.GetKey() != (QuadKey{1, 2, 3, 5}) {
+		t.Errorf("GetQuad(1, 2, 3, 5) = %v", p)
+	}
+	if n := len(*GetOfferMap()); n != 3 {
+		t.Errorf("offers = %d, want 3", n)
+	}
+}
+`,
+	})
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"go/types"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// validateTags 生成校验代码的字段标签，len<N 和 len<=N 解析后的键为 len<
+var validateTags = []string{"min", "max", "oneof", "regex", "len<", "len>", "len"}
+
+// hasValidate 表是否有字段声明了校验标签
+func hasValidate(ts *TableStruct) bool {
+	for _, f := range columnFields(ts) {
+		for _, t := range validateTags {
+			if f.hasAttr(t) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// basicInfo 返回字段底层基础类型的信息，非基础类型返回0
+func basicInfo(f *TableField) types.BasicInfo {
+	if f.vtype == nil {
+		return 0
+	}
+	if b, ok := f.vtype.Underlying().(*types.Basic); ok {
+		return b.Info()
+	}
+	return 0
+}
+
+// checkLiteral 检查标签中的值能否作为字段类型的常量
+func checkLiteral(ts *TableStruct, f *TableField, tag, v string) string {
+	info := basicInfo(f)
+	var err error
+	switch {
+	case info&types.IsInteger != 0:
+		_, err = strconv.ParseInt(v, 10, 64)
+	case info&types.IsFloat != 0:
+		_, err = strconv.ParseFloat(v, 64)
+	case info&types.IsString != 0:
+		return strconv.Quote(v)
+	default:
+		log.Fatalf("%s.%s: %s needs a numeric or string field", ts.typeName, f.name, tag)
+	}
+	if err != nil {
+		log.Fatalf("%s.%s: bad %s value %q: %v", ts.typeName, f.name, tag, v, err)
+	}
+	return v
+}
+
+// makeValidateOne 生成validateX，逐个检查字段约束，返回所有违规
+func makeValidateOne(ts *TableStruct, output map[string]string) {
+	if !hasValidate(ts) {
+		return
+	}
+	addImport(output, "fmt")
+
+	checks := make([]string, 0)
+	check := func(f *TableField, cond, msg string) {
+		format := f.name + " %v: " + strings.ReplaceAll(msg, "%", "%%")
+		checks = append(checks, fmt.Sprintf(validateCheck, cond, format, f.name))
+	}
+	for _, f := range columnFields(ts) {
+		if v, ok := f.attrs["min"]; ok {
+			if basicInfo(f)&types.IsNumeric == 0 {
+				log.Fatalf("%s.%s: min needs a numeric field", ts.typeName, f.name)
+			}
+			check(f, fmt.Sprintf("p.%s < %s", f.name, checkLiteral(ts, f, "min", v)), "must be >= "+v)
+		}
+		if v, ok := f.attrs["max"]; ok {
+			if basicInfo(f)&types.IsNumeric == 0 {
+				log.Fatalf("%s.%s: max needs a numeric field", ts.typeName, f.name)
+			}
+			check(f, fmt.Sprintf("p.%s > %s", f.name, checkLiteral(ts, f, "max", v)), "must be <= "+v)
+		}
+		if v, ok := f.attrs["oneof"]; ok {
+			values := make([]string, 0)
+			e := enumOf(ts, f)
+			for _, s := range strings.Split(v, "|") {
+				if e == nil {
+					values = append(values, checkLiteral(ts, f, "oneof", s))
+					continue
+				}
+				i, ok := e.lookup(s)
+				switch {
+				case ok && types.Identical(f.vtype, e.named):
+					values = append(values, e.consts[i].Name())
+				case ok:
+					values = append(values, e.consts[i].Val().ExactString())
+				default:
+					log.Fatalf("%s.%s: oneof: unknown %s %s", ts.typeName, f.name, e.name(), s)
+				}
+			}
+			addImport(output, "slices")
+			check(f, fmt.Sprintf("!slices.Contains([]%s{%s}, p.%s)", typeString(f, output), strings.Join(values, ", "), f.name), "must be one of "+v)
+		}
+		if v, ok := f.attrs["regex"]; ok {
+			if basicInfo(f)&types.IsString == 0 {
+				log.Fatalf("%s.%s: regex needs a string field", ts.typeName, f.name)
+			}
+			if _, err := regexp.Compile(v); err != nil {
+				log.Fatalf("%s.%s: bad regex %q: %v", ts.typeName, f.name, v, err)
+			}
+			addImport(output, "regexp")
+			name := firstCharLower(ts.typeName) + f.name + "Regexp"
+			output["load"] += fmt.Sprintf(validateRegexp, name, v)
+			check(f, fmt.Sprintf("!%s.MatchString(string(p.%s))", name, f.name), "must match "+v)
+		}
+		for _, op := range []string{"len<", "len>", "len"} {
+			v, ok := f.attrs[op]
+			if !ok {
+				continue
+			}
+			if _, err := strconv.Atoi(v); err != nil {
+				log.Fatalf("%s.%s: bad %s=%s", ts.typeName, f.name, op, v)
+			}
+			length := fmt.Sprintf("len(p.%s)", f.name)
+			if basicInfo(f)&types.IsString != 0 {
+				// 字符串按字符数计算长度
+				addImport(output, "unicode/utf8")
+				length = fmt.Sprintf("utf8.RuneCountInString(string(p.%s))", f.name)
+			} else if f.vtype == nil {
+				log.Fatalf("%s.%s: can not resolve type %s", ts.typeName, f.name, f.typ)
+			} else {
+				switch f.vtype.Underlying().(type) {
+				case *types.Slice, *types.Map, *types.Array:
+				default:
+					log.Fatalf("%s.%s: %s needs a string, slice or map field", ts.typeName, f.name, op)
+				}
+			}
+			switch op {
+			case "len<":
+				check(f, fmt.Sprintf("%s > %s", length, v), "length must be <= "+v)
+			case "len>":
+				check(f, fmt.Sprintf("%s < %s", length, v), "length must be >= "+v)
+			default:
+				check(f, fmt.Sprintf("%s != %s", length, v), "length must be "+v)
+			}
+		}
+	}
+	output["load"] += fmt.Sprintf(validateFunc, ts.typeName, ts.typeName, strings.Join(checks, ""))
+}
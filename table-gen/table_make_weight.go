@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"go/types"
+	"log"
+)
+
+// weightField 返回标记 gtable:"weight" 的字段，每张表最多一个
+func weightField(ts *TableStruct) *TableField {
+	var w *TableField
+	for _, f := range ts.fields {
+		if !f.hasAttr("weight") {
+			continue
+		}
+		if w != nil {
+			log.Fatalf("%s: only one weight field is allowed, found %s and %s", ts.typeName, w.name, f.name)
+		}
+		w = f
+	}
+	return w
+}
+
+// makeWeight 为 gtable:"weight" 或 gtable:"weight=Group" 生成加载时计算的权重前缀和，
+// 返回按权重随机的函数
+func makeWeight(ts *TableStruct, varIndex *int, output, getMap map[string]string, _make, _append, _stage *[]string) string {
+	f := weightField(ts)
+	if f == nil {
+		return ""
+	}
+	if f.vtype == nil {
+		log.Fatalf("%s.%s: can not resolve type %s", ts.typeName, f.name, f.typ)
+	}
+	if b, ok := f.vtype.Underlying().(*types.Basic); !ok || b.Info()&types.IsInteger == 0 {
+		log.Fatalf("%s.%s: weight field must be an integer type", ts.typeName, f.name)
+	}
+
+	n := ts.typeName
+	typeName := fmt.Sprintf(weightTypeName, n)
+	varName := fmt.Sprintf(weightVarName, n)
+	varTmp := fmt.Sprintf("var%d", *varIndex)
+	*varIndex++
+	output["typePattern"] += fmt.Sprintf(weightTypePattern, typeName, n)
+	output["varPattern"] += fmt.Sprintf(groupVarPattern, varName, typeName)
+	*_make = append(*_make, fmt.Sprintf(weightMake, varTmp, n, n))
+	appendPublish(varName, varTmp, _append, _stage)
+	funcs := fmt.Sprintf(weightFuncs, n, n, f.name, n, f.name, n, n, varName)
+	if *snapshotMode {
+		getMap["snapField"] += fmt.Sprintf(snapField, typeName, typeName)
+		getMap["snapLoad"] += fmt.Sprintf(snapLoadCustom, varName, typeName)
+		getMap["snapFunc"] += fmt.Sprintf(snapWeightFunc, n, n, typeName)
+	}
+
+	group := f.attrs["weight"]
+	if group == "" {
+		return funcs
+	}
+	g := ts.getField(group)
+	if g == nil {
+		log.Fatalf("%s.%s: weight group field %s not found", ts.typeName, f.name, group)
+	}
+	typeName = fmt.Sprintf(weightGroupTypeName, n, group)
+	varName = fmt.Sprintf(weightGroupVarName, n, group)
+	varTmp = fmt.Sprintf("var%d", *varIndex)
+	*varIndex++
+	output["typePattern"] += fmt.Sprintf(weightGroupTypePattern, typeName, g.typ, n)
+	output["varPattern"] += fmt.Sprintf(groupVarPattern, varName, typeName)
+	*_make = append(*_make, fmt.Sprintf(weightGroupMake, varTmp, n, n, g.typ, group, n))
+	appendPublish(varName, varTmp, _append, _stage)
+	funcs += fmt.Sprintf(weightGroupFunc, n, group, group, f.name, n, group, g.typ, n, varName)
+	if *snapshotMode {
+		getMap["snapField"] += fmt.Sprintf(snapField, typeName, typeName)
+		getMap["snapLoad"] += fmt.Sprintf(snapLoadCustom, varName, typeName)
+		getMap["snapFunc"] += fmt.Sprintf(snapWeightGroupFunc, n, group, g.typ, n, typeName)
+	}
+	return funcs
+}
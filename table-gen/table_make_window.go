@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"go/types"
+	"log"
+)
+
+// windowSpec 有效时间段，来自 @window start=StartTime end=EndTime
+type windowSpec struct {
+	start string
+	end   string
+}
+
+// parseWindowSpec 解析 @window 的参数，省略时为StartTime和EndTime
+func parseWindowSpec(opts map[string]string) *windowSpec {
+	w := &windowSpec{start: "StartTime", end: "EndTime"}
+	if v, ok := opts["start"]; ok {
+		w.start = v
+	}
+	if v, ok := opts["end"]; ok {
+		w.end = v
+	}
+	return w
+}
+
+// windowBound 返回起止字段转换为time.Time的表达式，字段为time.Time或秒级时间戳
+func windowBound(ts *TableStruct, name string) string {
+	f := ts.getField(name)
+	if f == nil {
+		log.Fatalf("window field %s not found in struct %s", name, ts.typeName)
+	}
+	if isTime(f) {
+		return "p." + name
+	}
+	if b, ok := f.vtype.Underlying().(*types.Basic); ok && b.Info()&types.IsInteger != 0 {
+		return fmt.Sprintf("gtrt.UnixTime(int64(p.%s))", name)
+	}
+	log.Fatalf("window field %s.%s must be time.Time or an integer unix timestamp", ts.typeName, name)
+	return ""
+}
+
+// makeWindow 生成加载时重建的时间段索引，返回按时间查询有效行的函数
+func makeWindow(ts *TableStruct, varIndex *int, output, getMap map[string]string, _make, _append, _stage *[]string) string {
+	w := ts.window
+	if w == nil {
+		return ""
+	}
+	start, end := windowBound(ts, w.start), windowBound(ts, w.end)
+	typeName := fmt.Sprintf(windowTypeName, ts.typeName)
+	varName := fmt.Sprintf(windowVarName, ts.typeName)
+	varTmp := fmt.Sprintf("var%d", *varIndex)
+	*varIndex++
+
+	output["typePattern"] += fmt.Sprintf(windowTypePattern, typeName, ts.typeName)
+	output["varPattern"] += fmt.Sprintf(groupVarPattern, varName, typeName)
+	*_make = append(*_make, fmt.Sprintf(windowMake, varTmp, ts.typeName, ts.typeName))
+	appendPublish(varName, varTmp, _append, _stage)
+
+	params, call, _, _ := ts.GenKeyParams()
+	if call == "" {
+		call = "key"
+	}
+	n := ts.typeName
+	addImport(output, "time")
+
+	if *snapshotMode {
+		getMap["snapField"] += fmt.Sprintf(snapField, typeName, typeName)
+		getMap["snapLoad"] += fmt.Sprintf(snapLoadCustom, varName, typeName)
+		getMap["snapFunc"] += fmt.Sprintf(snapWindowFunc, n, n, typeName, n, params, n, n, call, n, n, typeName)
+		addSnapImport(getMap, "time")
+	}
+	return fmt.Sprintf(windowFuncs, n, n, start, end, n, n, n, varName, n, n, params, n, n, call, n, n, n, varName)
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/constant"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const generatedHeader = "// Code generated by table-gen. DO NOT EDIT."
+
+// sourcePkg 当前目录包的语法树及类型信息，只解析一次
+type sourcePkg struct {
+	fset   *token.FileSet
+	files  []*ast.File
+	pkg    *types.Package
+	info   *types.Info
+	values map[types.Object]ast.Expr // 变量的初始化表达式
+}
+
+var srcPkg *sourcePkg
+
+// loadSource 解析并类型检查当前目录的Go文件，跳过table-gen生成的文件
+// 生成文件缺失导致的类型错误会被忽略，常量仍可正常求值
+func loadSource() *sourcePkg {
+	if srcPkg != nil {
+		return srcPkg
+	}
+
+	p := &sourcePkg{
+		fset: token.NewFileSet(),
+		info: &types.Info{
+			Types: make(map[ast.Expr]types.TypeAndValue),
+			Defs:  make(map[*ast.Ident]types.Object),
+			Uses:  make(map[*ast.Ident]types.Object),
+		},
+		values: make(map[types.Object]ast.Expr),
+	}
+	files, _ := filepath.Glob("./*.go")
+	for _, file := range files {
+		if strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		if ok, err := build.Default.MatchFile(".", filepath.Base(file)); err != nil || !ok {
+			continue
+		}
+		fc, err := os.ReadFile(file)
+		if err != nil || strings.HasPrefix(string(fc), generatedHeader) {
+			continue
+		}
+		f, err := parser.ParseFile(p.fset, file, fc, parser.ParseComments)
+		if err != nil {
+			log.Printf("warning: failed to parse file %s: %v", file, err)
+			continue
+		}
+		p.files = append(p.files, f)
+	}
+
+	conf := types.Config{
+		Importer: importer.ForCompiler(p.fset, "source", nil),
+		Error:    func(error) {},
+	}
+	name := "gtable"
+	if len(p.files) > 0 {
+		name = p.files[0].Name.Name
+	}
+	p.pkg, _ = conf.Check(name, p.fset, p.files, p.info)
+
+	// 记录变量初始化表达式，用于追踪 return names 这类写法
+	for _, f := range p.files {
+		ast.Inspect(f, func(n ast.Node) bool {
+			switch x := n.(type) {
+			case *ast.ValueSpec:
+				if len(x.Names) == len(x.Values) {
+					for i, v := range x.Names {
+						p.values[p.info.Defs[v]] = x.Values[i]
+					}
+				}
+			case *ast.AssignStmt:
+				if x.Tok == token.DEFINE && len(x.Lhs) == len(x.Rhs) {
+					for i, v := range x.Lhs {
+						if ident, ok := v.(*ast.Ident); ok && p.info.Defs[ident] != nil {
+							p.values[p.info.Defs[ident]] = x.Rhs[i]
+						}
+					}
+				}
+			}
+			return true
+		})
+	}
+	srcPkg = p
+	return p
+}
+
+// cFiles 返回c_*.go文件的语法树
+func (p *sourcePkg) cFiles() []*ast.File {
+	lst := make([]*ast.File, 0, len(p.files))
+	for _, f := range p.files {
+		if strings.HasPrefix(filepath.Base(p.fset.Position(f.Pos()).Filename), "c_") {
+			lst = append(lst, f)
+		}
+	}
+	return lst
+}
+
+// recvName 返回方法接收者的类型名，支持指针和值接收者
+func recvName(funcDecl *ast.FuncDecl) string {
+	if funcDecl.Recv == nil || len(funcDecl.Recv.List) == 0 {
+		return ""
+	}
+	typ := funcDecl.Recv.List[0].Type
+	if star, ok := typ.(*ast.StarExpr); ok {
+		typ = star.X
+	}
+	if ident, ok := typ.(*ast.Ident); ok {
+		return ident.Name
+	}
+	return ""
+}
+
+// evalStrings 静态求值字符串切片表达式，支持字面量、常量和变量
+func (p *sourcePkg) evalStrings(expr ast.Expr) ([]string, error) {
+	switch x := ast.Unparen(expr).(type) {
+	case *ast.CompositeLit:
+		names := make([]string, 0, len(x.Elts))
+		for _, elt := range x.Elts {
+			if kv, ok := elt.(*ast.KeyValueExpr); ok {
+				elt = kv.Value
+			}
+			tv, ok := p.info.Types[elt]
+			if !ok || tv.Value == nil || tv.Value.Kind() != constant.String {
+				return nil, fmt.Errorf("%s: %s is not a string constant", p.fset.Position(elt.Pos()), types.ExprString(elt))
+			}
+			names = append(names, constant.StringVal(tv.Value))
+		}
+		return names, nil
+	case *ast.Ident:
+		if obj, ok := p.info.Uses[x].(*types.Var); ok {
+			if v, ok := p.values[obj]; ok {
+				return p.evalStrings(v)
+			}
+		}
+		if x.Name == "nil" {
+			return nil, nil
+		}
+	}
+	return nil, fmt.Errorf("%s: %s is not a []string literal, constant or variable", p.fset.Position(expr.Pos()), types.ExprString(expr))
+}
@@ -0,0 +1,363 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// xlsxBook 只读的xlsx工作簿，仅支持读取单元格文本，不依赖第三方库
+type xlsxBook struct {
+	zr       *zip.ReadCloser
+	sheets   []xlsxSheet
+	strings  []string // sharedStrings
+	dates    []bool   // 按单元格样式下标，数字格式是否为日期时间
+	date1904 bool
+}
+
+// xlsxTimeLayout 日期单元格导出的格式，gtrt.ParseTime可以解析
+const xlsxTimeLayout = "2006-01-02 15:04:05"
+
+type xlsxSheet struct {
+	name string
+	path string // zip内路径，如 xl/worksheets/sheet1.xml
+}
+
+func openXlsx(file string) (*xlsxBook, error) {
+	zr, err := zip.OpenReader(file)
+	if err != nil {
+		return nil, err
+	}
+	b := &xlsxBook{zr: zr}
+	if err := b.readWorkbook(); err != nil {
+		zr.Close()
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	if err := b.readSharedStrings(); err != nil {
+		zr.Close()
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	if err := b.readStyles(); err != nil {
+		zr.Close()
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	return b, nil
+}
+
+func (b *xlsxBook) Close() error {
+	return b.zr.Close()
+}
+
+// sheetNames 按工作簿中的顺序返回工作表名
+func (b *xlsxBook) sheetNames() []string {
+	lst := make([]string, 0, len(b.sheets))
+	for _, v := range b.sheets {
+		lst = append(lst, v.name)
+	}
+	return lst
+}
+
+func (b *xlsxBook) decode(name string, v any) error {
+	f, err := b.zr.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return xml.NewDecoder(f).Decode(v)
+}
+
+func (b *xlsxBook) readWorkbook() error {
+	var wb struct {
+		Pr struct {
+			Date1904 string `xml:"date1904,attr"`
+		} `xml:"workbookPr"`
+		Sheets []struct {
+			Name string `xml:"name,attr"`
+			RID  string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	if err := b.decode("xl/workbook.xml", &wb); err != nil {
+		return err
+	}
+	b.date1904 = wb.Pr.Date1904 == "1" || wb.Pr.Date1904 == "true"
+	var rels struct {
+		Rels []struct {
+			ID     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	if err := b.decode("xl/_rels/workbook.xml.rels", &rels); err != nil {
+		return err
+	}
+	targets := make(map[string]string, len(rels.Rels))
+	for _, v := range rels.Rels {
+		t := v.Target
+		if strings.HasPrefix(t, "/") {
+			t = strings.TrimPrefix(t, "/")
+		} else {
+			t = path.Join("xl", t)
+		}
+		targets[v.ID] = t
+	}
+	for _, v := range wb.Sheets {
+		t, ok := targets[v.RID]
+		if !ok {
+			return fmt.Errorf("sheet %s has no relationship %s", v.Name, v.RID)
+		}
+		b.sheets = append(b.sheets, xlsxSheet{name: v.Name, path: t})
+	}
+	return nil
+}
+
+// readSharedStrings 读取共享字符串表，富文本按片段拼接
+func (b *xlsxBook) readSharedStrings() error {
+	var sst struct {
+		SI []struct {
+			T string `xml:"t"`
+			R []struct {
+				T string `xml:"t"`
+			} `xml:"r"`
+		} `xml:"si"`
+	}
+	if err := b.decode("xl/sharedStrings.xml", &sst); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	b.strings = make([]string, 0, len(sst.SI))
+	for _, v := range sst.SI {
+		s := v.T
+		for _, r := range v.R {
+			s += r.T
+		}
+		b.strings = append(b.strings, s)
+	}
+	return nil
+}
+
+// readStyles 读取单元格样式，记录哪些样式的数字格式是日期时间
+func (b *xlsxBook) readStyles() error {
+	var ss struct {
+		NumFmts []struct {
+			ID   int    `xml:"numFmtId,attr"`
+			Code string `xml:"formatCode,attr"`
+		} `xml:"numFmts>numFmt"`
+		Xfs []struct {
+			NumFmtID int `xml:"numFmtId,attr"`
+		} `xml:"cellXfs>xf"`
+	}
+	if err := b.decode("xl/styles.xml", &ss); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	custom := make(map[int]string, len(ss.NumFmts))
+	for _, v := range ss.NumFmts {
+		custom[v.ID] = v.Code
+	}
+	b.dates = make([]bool, len(ss.Xfs))
+	for i, v := range ss.Xfs {
+		if code, ok := custom[v.NumFmtID]; ok {
+			b.dates[i] = isDateFormat(code)
+		} else {
+			b.dates[i] = isDateFormatID(v.NumFmtID)
+		}
+	}
+	return nil
+}
+
+// isDateFormatID 内置数字格式中的日期时间格式，27~36和50~58为东亚语言的日期格式
+func isDateFormatID(id int) bool {
+	return id >= 14 && id <= 22 || id >= 27 && id <= 36 || id >= 45 && id <= 47 || id >= 50 && id <= 58
+}
+
+// isDateFormat 自定义数字格式去掉引号中的文字、转义字符和[]中的颜色等之后是否含有日期时间占位符
+func isDateFormat(code string) bool {
+	quoted, bracket, escaped := false, false, false
+	for _, c := range strings.ToLower(code) {
+		switch {
+		case escaped:
+			escaped = false
+		case quoted:
+			quoted = c != '"'
+		case bracket:
+			bracket = c != ']'
+		case c == '"':
+			quoted = true
+		case c == '[':
+			bracket = true
+		case c == '\\' || c == '_' || c == '*':
+			escaped = true
+		case c == ';':
+			// 只看第一段格式
+			return false
+		case strings.ContainsRune("ymdhs", c):
+			return true
+		}
+	}
+	return false
+}
+
+// excelTime 把日期序列号转换为时间，序列号的整数部分是天，小数部分是一天中的时间
+func (b *xlsxBook) excelTime(serial float64) time.Time {
+	epoch := time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+	if b.date1904 {
+		epoch = time.Date(1904, 1, 1, 0, 0, 0, 0, time.UTC)
+	} else if serial < 60 {
+		// 1900日期系统把1900-02-29当作存在的日期，之前的序列号多算了一天
+		serial++
+	}
+	return epoch.Add(time.Duration(math.Round(serial*86400)) * time.Second)
+}
+
+// readSheet 读取工作表所有行，缺失的单元格补空串，sheet为空时读取第一个工作表
+func (b *xlsxBook) readSheet(sheet string) ([][]string, error) {
+	var ws *xlsxSheet
+	for i := range b.sheets {
+		if sheet == "" || b.sheets[i].name == sheet {
+			ws = &b.sheets[i]
+			break
+		}
+	}
+	if ws == nil {
+		return nil, fmt.Errorf("sheet %s not found", sheet)
+	}
+
+	f, err := b.zr.Open(ws.path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	type cell struct {
+		R  string `xml:"r,attr"`
+		T  string `xml:"t,attr"`
+		S  int    `xml:"s,attr"`
+		V  string `xml:"v"`
+		IS struct {
+			T string `xml:"t"`
+			R []struct {
+				T string `xml:"t"`
+			} `xml:"r"`
+		} `xml:"is"`
+	}
+	type row struct {
+		R int    `xml:"r,attr"`
+		C []cell `xml:"c"`
+	}
+
+	rows := make([][]string, 0)
+	dec := xml.NewDecoder(f)
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", ws.name, err)
+		}
+		se, ok := tok.(xml.StartElement)
+		if !ok || se.Name.Local != "row" {
+			continue
+		}
+		var r row
+		if err := dec.DecodeElement(&r, &se); err != nil {
+			return nil, fmt.Errorf("%s: %w", ws.name, err)
+		}
+		// 跳过的空行补齐，保持与表格中的行号一致
+		for r.R > len(rows)+1 {
+			rows = append(rows, nil)
+		}
+		line := make([]string, 0, len(r.C))
+		for _, c := range r.C {
+			col := len(line)
+			if c.R != "" {
+				if col, err = xlsxColumn(c.R); err != nil {
+					return nil, fmt.Errorf("%s: %w", ws.name, err)
+				}
+			}
+			for len(line) < col {
+				line = append(line, "")
+			}
+			inline := c.IS.T
+			for _, t := range c.IS.R {
+				inline += t.T
+			}
+			v, err := b.cellValue(c.T, c.S, c.V, inline)
+			if err != nil {
+				return nil, fmt.Errorf("%s!%s: %w", ws.name, c.R, err)
+			}
+			line = append(line, v)
+		}
+		rows = append(rows, line)
+	}
+	return rows, nil
+}
+
+// cellValue 返回单元格的文本，日期格式的数字按xlsxTimeLayout输出
+func (b *xlsxBook) cellValue(typ string, style int, v, inline string) (string, error) {
+	if v == "" && typ != "inlineStr" {
+		return "", nil
+	}
+	switch typ {
+	case "s":
+		i, err := strconv.Atoi(v)
+		if err != nil || i < 0 || i >= len(b.strings) {
+			return "", fmt.Errorf("bad shared string index %q", v)
+		}
+		return b.strings[i], nil
+	case "inlineStr":
+		return inline, nil
+	case "b":
+		if v == "1" {
+			return "true", nil
+		}
+		return "false", nil
+	case "d":
+		// ISO 8601格式的日期单元格
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999", "2006-01-02"} {
+			if t, err := time.Parse(layout, v); err == nil {
+				return t.Format(xlsxTimeLayout), nil
+			}
+		}
+		return "", fmt.Errorf("bad date %q", v)
+	case "", "n":
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			break
+		}
+		if style >= 0 && style < len(b.dates) && b.dates[style] {
+			return b.excelTime(f).Format(xlsxTimeLayout), nil
+		}
+		// 数字按最短形式输出，避免0.1被写成0.10000000000000001
+		return strconv.FormatFloat(f, 'f', -1, 64), nil
+	}
+	return v, nil
+}
+
+// xlsxColumn 将单元格引用如 "AB12" 转换为从0开始的列号
+func xlsxColumn(ref string) (int, error) {
+	col := 0
+	n := 0
+	for _, c := range ref {
+		if c < 'A' || c > 'Z' {
+			break
+		}
+		col = col*26 + int(c-'A'+1)
+		n++
+	}
+	if n == 0 {
+		return 0, fmt.Errorf("bad cell reference %q", ref)
+	}
+	return col - 1, nil
+}